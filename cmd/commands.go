@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"jakeblog/internal/blog"
+	"strings"
+)
+
+const envPrefix = "BLOG_"
+
+// newFlagSet has a flag for every BLOG_ env var. the option applies the ones set
+func newFlagSet(name string) (*flag.FlagSet, blog.ConfigOption) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, blog.ConfigFlags(fs, envPrefix)
+}
+
+func serve(args []string) error {
+	fs, flags := newFlagSet("serve")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return blog.StartBlogServer(flags)
+}
+
+func build(args []string) error {
+	fs, flags := newFlagSet("build")
+	out := fs.String("out", "public", "directory the static site replaces")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return blog.BuildSite(envPrefix, *out, flags)
+}
+
+// check prints the update report and fails when there is anything in it
+func check(args []string) error {
+	fs, flags := newFlagSet("check")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := blog.CheckSite(envPrefix, flags)
+	if !report.Time.IsZero() {
+		if strings.Trim(report.Commit, "0") != "" { // LocalOnly content has no commit
+			fmt.Printf("commit %s\n", report.Commit)
+		}
+		fmt.Printf("%d files rendered, %d drafts, %d articles served\n", report.Rendered, report.Drafts, report.Serving)
+	}
+	for _, issue := range report.Issues {
+		fmt.Printf("%s: %s: %s\n", issue.Source, issue.Check, issue.Message)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("%d validation issues", len(report.Issues))
+	}
+	fmt.Println("no issues found")
+	return nil
+}
+
+func newPost(args []string) error {
+	fs, flags := newFlagSet("new")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: jake-blog new [flags] <slug>\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("new takes exactly one slug")
+	}
+
+	name, err := blog.NewPost(envPrefix, fs.Arg(0), flags)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
+
+func reload(args []string) error {
+	fs, flags := newFlagSet("reload")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := blog.Reload(envPrefix, flags); err != nil {
+		return err
+	}
+	fmt.Println("content update triggered")
+	return nil
+}
@@ -2,14 +2,50 @@ package main
 
 import (
 	"fmt"
-	"jakeblog/internal/blog"
+	"os"
+	"strings"
 )
 
+const usage = `usage: jake-blog [command] [flags]
+
+commands:
+  serve       serve the blog, the default
+  build       write every route to a directory for static hosting
+  check       validate the config and content and report any issues
+  new <slug>  scaffold a draft post in the content directory
+  reload      tell a running server to update its content
+
+every command takes flags that override the BLOG_ env vars, see jake-blog <command> -h
+`
+
+var commands = map[string]func(args []string) error{
+	"serve":  serve,
+	"build":  build,
+	"check":  check,
+	"new":    newPost,
+	"reload": reload,
+}
+
 func main() {
 	initZLOG(INFO)
 
-	err := blog.StartBlogServer()
-	if err != nil {
-		initLogMSG(ERROR, fmt.Sprintf("blogserver stopped with error: %v", err))
+	// no command keeps the server the default for existing deployments
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return
+	}
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+
+	if err := run(args); err != nil {
+		initLogMSG(ERROR, fmt.Sprintf("%s stopped with error: %v", name, err))
+		os.Exit(1)
 	}
 }
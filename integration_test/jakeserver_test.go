@@ -2,6 +2,8 @@ package integration_test
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"jakeblog/internal/blog"
@@ -9,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,6 +43,15 @@ This is a test article's content.`
 	err := os.WriteFile(filepath.Join(contentDir, "test.md"), []byte(testContent), 0644)
 	require.NoError(t, err)
 
+	taggedContent := `---
+title: Tagged Article
+date: 2024-01-02
+tags: [Go, site reliability]
+---
+This article has tags.`
+	err = os.WriteFile(filepath.Join(contentDir, "tagged.md"), []byte(taggedContent), 0644)
+	require.NoError(t, err)
+
 	// create dummy SSH key
 	err = os.WriteFile(keyPath, []byte("dummy-key"), 0600)
 	require.NoError(t, err)
@@ -153,6 +165,47 @@ This is a test article's content.`
 			expectedStatus: http.StatusOK,
 			expectedBody:   "This is a test article",
 		},
+		{
+			name:           "tag list", // verify tags are listed with counts
+			path:           "/tags/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "site-reliability</a> <span class=\"count\">(1)</span>",
+		},
+		{
+			name:           "tag article list", // verify tagged articles are listed for a tag
+			path:           "/tags/go",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "Tagged Article",
+		},
+		{
+			name:           "non-existent tag", // verify correct response from missing tag
+			path:           "/tags/doesnotexist",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "tag rss feed", // verify rss feed is filtered by tag
+			path:           "/feed/tags/site-reliability",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "https://jake-henning.com/article/tagged",
+		},
+		{
+			name:           "atom feed", // verify atom feed carries tagged article categories
+			path:           "/feed/atom.xml",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `<category term="site-reliability"></category>`,
+		},
+		{
+			name:           "json feed", // verify json feed carries full article content
+			path:           "/feed/feed.json",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"version": "https://jsonfeed.org/version/1.1"`,
+		},
+		{
+			name:           "search", // verify search finds articles by body text
+			path:           "/search?q=tags",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<mark>tags</mark>",
+		},
 		{
 			name:           "non-existent article", // verify correct response from missing article
 			path:           "/article/doesnotexist",
@@ -194,3 +247,96 @@ This is a test article's content.`
 		require.Contains(t, string(body), "blog.articles.served: 1")
 	})
 }
+
+// memImageStore stands in for the image bucket
+type memImageStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memImageStore) Objects(ctx context.Context) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects := make(map[string]string, len(m.objects))
+	for key, body := range m.objects {
+		sum := md5.Sum(body)
+		objects[key] = hex.EncodeToString(sum[:])
+	}
+	return objects, nil
+}
+
+func (m *memImageStore) Put(ctx context.Context, key string, body []byte, immutable bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = body
+	return nil
+}
+
+func (m *memImageStore) has(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, found := m.objects[key]
+	return found
+}
+
+// kept after TestBlogServerIntegration, whose metrics rely on its server installing
+// the first meter provider
+func TestImagePublishing(t *testing.T) {
+	contentDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(contentDir, "images"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(contentDir, "images", "diagram.svg"), []byte("<svg></svg>"), 0644))
+	article := "# Pictures\nA diagram ![diagram](images/diagram.svg)"
+	require.NoError(t, os.WriteFile(filepath.Join(contentDir, "pictures.md"), []byte(article), 0644))
+
+	port, err := getFreePort()
+	require.NoError(t, err)
+
+	env := map[string]string{
+		"IMG_LOCAL_ONLY":       "true",
+		"IMG_SERVER_PORT":      port,
+		"IMG_CONTENT_DIR":      contentDir,
+		"IMG_REPO_URL":         "dummy-url",
+		"IMG_ENVMNT":           "test",
+		"IMG_IMAGECACHE":       "true",
+		"IMG_IMAGE_BUCKET":     "images",
+		"IMG_IMAGE_BUCKET_URL": "http://images.localhost:9000/",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	store := &memImageStore{objects: make(map[string][]byte)}
+	bs, err := blog.NewBlogServer(
+		blog.WithConfig("IMG_"),
+		blog.WithImageStore(store),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		if err := bs.Start(); err != nil {
+			t.Errorf("server error: %v", err)
+		}
+	}()
+
+	client := &http.Client{Timeout: 1 * time.Second}
+	var resp *http.Response
+	var body []byte
+	startTime := time.Now()
+	for time.Since(startTime) < 5*time.Second {
+		resp, err = client.Get("http://localhost:" + port + "/article/pictures")
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.NoError(t, err, "server failed to start and serve content")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.True(t, store.has("diagram.svg"), "images are published before articles link to them")
+	require.Contains(t, string(body), `src="http://images.localhost:9000/diagram.svg"`)
+	require.Contains(t, resp.Header.Get("Content-Security-Policy"), "img-src 'self' http://images.localhost:9000;")
+}
@@ -0,0 +1,117 @@
+package blog
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	adminRateBurst  = 10
+	adminRateRefill = 6 * time.Second
+)
+
+// wrapAdmin requires the admin bearer token and method on every request
+func (s *Server) wrapAdmin(h http.Handler, method, name string) http.Handler {
+	want := []byte("Bearer " + s.bm.Config.AdminToken)
+
+	validateHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		if !s.adminLimiter.Allow() {
+			s.reqBlockedInstrument("ADMIN_RATE_LIMITED", r.Context())
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			s.reqBlockedInstrument("ADMIN_AUTH", r.Context())
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Method != method {
+			s.reqBlockedInstrument("BAD_METHOD", r.Context())
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+
+	return otelhttp.NewHandler(validateHandler, name,
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return "Admin " + r.URL.Path
+		}),
+	)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// AdminSnapshots lists the retained content snapshots newest first
+func (s *Server) AdminSnapshots(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "AdminSnapshotsHandler.Process")
+	defer span.End()
+
+	if err := writeJSON(w, http.StatusOK, s.bm.Snapshots()); err != nil {
+		serverLogger.Error().Msgf("failed to send snapshots to client: %v", err)
+		span.SetAttributes(attribute.String("error", "write failed"))
+	}
+}
+
+// AdminReport is the report of the last content update including validation issues
+func (s *Server) AdminReport(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "AdminReportHandler.Process")
+	defer span.End()
+
+	if err := writeJSON(w, http.StatusOK, s.bm.LastUpdateReport()); err != nil {
+		serverLogger.Error().Msgf("failed to send update report to client: %v", err)
+		span.SetAttributes(attribute.String("error", "write failed"))
+	}
+}
+
+// AdminRollback pins the snapshot named by the commit parameter, or the previous one without it
+func (s *Server) AdminRollback(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "AdminRollbackHandler.Process")
+	defer span.End()
+
+	commit := strings.TrimSpace(r.FormValue("commit"))
+	pinned, err := s.bm.Rollback(commit)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", err.Error()))
+		status := http.StatusConflict
+		if errors.Is(err, errNoSnapshot) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	span.SetAttributes(attribute.String("content.pinned", pinned))
+
+	if err := writeJSON(w, http.StatusOK, map[string]string{"pinned": pinned}); err != nil {
+		serverLogger.Error().Msgf("failed to send rollback result to client: %v", err)
+	}
+}
+
+// AdminUnpin returns to serving the newest content
+func (s *Server) AdminUnpin(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "AdminUnpinHandler.Process")
+	defer span.End()
+
+	if err := s.bm.Unpin(); err != nil {
+		span.SetAttributes(attribute.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
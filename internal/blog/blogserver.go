@@ -1,12 +1,34 @@
 // Package blog
-// config.go -> configuration from env vars
+// admin.go -> token protected admin endpoints
+// config.go -> configuration from env vars and flags
+// control.go -> unix socket the cli sends commands to a running server on
 // blogserver.go -> glues everything together
+// build.go -> static site export
+// dev.go -> content watcher and live reload for local writing
+// feeds.go -> atom and json feeds
+// frontmatter.go -> yaml front matter parsing
+// highlight.go -> server side syntax highlighting of code blocks
+// images.go -> responsive image variants processed at content update
+// imagestore.go -> publishing images to an s3 compatible bucket
 // log.go -> init loggers
 // manager.go -> actual blog implementation
 // markdown.go -> markdowm to html
+// post.go -> scaffolding of new posts
+// poll.go -> periodic check of the remote content branch
+// preview.go -> token protected previews of other content branches
+// search.go -> in memory full text search
+// schedule.go -> article states and scheduled publishing
 // otel.go -> otel instrumentation and exporters to telemetry struct
+// series.go -> content directories as article series
 // server.go -> http server
+// snapshot.go -> recent content builds kept for rollback
+// summary.go -> word counts, reading time and summaries
+// tags.go -> tag taxonomy lists and feeds
 // telemetry.go -> in memory telemetry store for blog
+// theme.go -> html/template layouts with overrides from the content repo
+// toc.go -> heading ids, anchors and tables of contents
+// validate.go -> pre-publish content checks and update reports
+// webhook.go -> signed push webhook that triggers content updates
 package blog
 
 import (
@@ -20,10 +42,12 @@ import (
 )
 
 type BlogServer struct {
-	server *Server                // http server
-	bm     *BlogManager           // content management
-	telem  *LocalTelemetryStorage // where otel data is exported
-	cfg    Config                 // configuration settings
+	server   *Server                 // http server
+	bm       *BlogManager            // content management
+	previews map[string]*BlogManager // content management per preview branch
+	telem    *LocalTelemetryStorage  // where otel data is exported
+	cfg      Config                  // configuration settings
+	images   ImageStore              // where images are published, s3 when nil
 
 	// Control channels
 	ctx     context.Context
@@ -33,10 +57,12 @@ type BlogServer struct {
 
 type BlogServerOption func(*BlogServer) error
 
-func WithConfig(envPrefix string) BlogServerOption {
+// WithConfig loads the config from env vars named envPrefix + the variable. opts
+// are applied after the environment so they override it
+func WithConfig(envPrefix string, opts ...ConfigOption) BlogServerOption {
 	return func(bs *BlogServer) error {
 		cfg, err := NewConfig(
-			withEnvironment(envPrefix),
+			append([]ConfigOption{withEnvironment(envPrefix)}, opts...)...,
 		)
 		if err != nil {
 			return fmt.Errorf("config creation failed: %w", err)
@@ -50,6 +76,14 @@ func WithConfig(envPrefix string) BlogServerOption {
 	}
 }
 
+// WithImageStore publishes images to store instead of the configured s3 bucket
+func WithImageStore(store ImageStore) BlogServerOption {
+	return func(bs *BlogServer) error {
+		bs.images = store
+		return nil
+	}
+}
+
 func NewBlogServer(opts ...BlogServerOption) (*BlogServer, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	lts := NewLocalTelemetryStorage()
@@ -70,10 +104,13 @@ func NewBlogServer(opts ...BlogServerOption) (*BlogServer, error) {
 	}
 
 	bs.bm = NewBlogManager(&bs.cfg)
+	bs.previews = newPreviewManagers(&bs.cfg)
+	bs.bm.imageStore = bs.images
 	bs.server = NewServer(bs.bm, bs.telem)
 	if bs.server == nil {
 		return nil, fmt.Errorf("could not initialize server")
 	}
+	bs.server.previews = bs.previews
 
 	return bs, nil
 }
@@ -92,8 +129,24 @@ func (bs *BlogServer) Start() error {
 
 	// Start blog manager updates
 	bs.bm.listenForUpdates(bs.ctx)
+	bs.bm.listenForSchedule(bs.ctx)
+	bs.bm.listenForRemote(bs.ctx)
+	bs.bm.listenForRollback(bs.ctx)
+	bs.bm.watchContent(bs.ctx)
 	bs.bm.TriggerUpdate()
 
+	for branch, preview := range bs.previews {
+		blogLogger.Info().Msgf("serving preview of branch %s", branch)
+		preview.listenForUpdates(bs.ctx)
+		preview.listenForSchedule(bs.ctx)
+		preview.listenForRemote(bs.ctx)
+		preview.TriggerUpdate()
+	}
+
+	if err := bs.listenForControl(bs.ctx); err != nil {
+		blogLogger.Warn().Msgf("serving without a control socket: %v", err)
+	}
+
 	err = bs.server.Start(bs.ctx)
 	if err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
@@ -119,9 +172,9 @@ func (bs *BlogServer) shutdown() error {
 	return nil
 }
 
-func StartBlogServer() error {
+func StartBlogServer(opts ...ConfigOption) error {
 	bs, err := NewBlogServer(
-		WithConfig("BLOG_"),
+		WithConfig("BLOG_", opts...),
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,332 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// a static build runs one content update and writes every route the server would
+// answer to a directory a static host can serve. pages go to {route}/index.html and
+// root relative links between written files are made relative so the mirror works
+// under any prefix. nothing in the output depends on when or where it was built
+// so two builds of the same commit are identical. LocalOnly content has no commits
+// so dates not set in front matter come from file mtimes and its builds only match
+// while those do
+
+// notFoundPage is what most static hosts serve for missing paths. it is served at
+// any depth so its links stay root relative
+const notFoundPage = "404.html"
+
+var linkAttrRe = regexp.MustCompile(`(\s(?:href|src|srcset|hx-get)=")([^"]*)(")`)
+
+// BuildSite loads the config like WithConfig and writes the static site to outDir
+func BuildSite(envPrefix, outDir string, opts ...ConfigOption) error {
+	cfg, err := NewConfig(append([]ConfigOption{withEnvironment(envPrefix)}, opts...)...)
+	if err != nil {
+		return fmt.Errorf("config creation failed: %w", err)
+	}
+	if err := cfg.initializePrivateKey(); err != nil {
+		return fmt.Errorf("private key initialization failed: %w", err)
+	}
+	return Build(cfg, outDir)
+}
+
+// Build runs the content update pipeline once and replaces outDir with the site
+func Build(cfg *Config, outDir string) error {
+	return buildSite(cfg, outDir, nil)
+}
+
+// buildSite is Build with the store images would be published to, which it never
+// is: the site links to its own copies of the images and a mirror must not write
+// to production storage
+func buildSite(cfg *Config, outDir string, store ImageStore) error {
+	if err := checkOutDir(cfg, outDir); err != nil {
+		return err
+	}
+
+	local := *cfg
+	local.IMAGECACHE = false
+	bm := NewBlogManager(&local)
+	bm.imageStore = store
+	if err := bm.updateContent(); err != nil {
+		return fmt.Errorf("content update failed: %w", err)
+	}
+	files, err := bm.siteFiles()
+	if err != nil {
+		return err
+	}
+
+	// built next to outDir and swapped in so a failed build leaves the last one
+	parent := filepath.Dir(filepath.Clean(outDir))
+	if err := os.MkdirAll(parent, 0o750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(parent, ".build-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	defer os.RemoveAll(tmp) // #nosec G104 -- gone after a successful rename
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dest := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil { // #nosec G301 -- served to the public
+			return fmt.Errorf("failed to create %s: %w", path.Dir(name), err)
+		}
+		if err := os.WriteFile(dest, files[name], 0o644); err != nil { // #nosec G306 -- served to the public
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := os.Chmod(tmp, 0o755); err != nil { // #nosec G302 -- served to the public
+		return fmt.Errorf("failed to write output directory: %w", err)
+	}
+
+	if err := os.RemoveAll(outDir); err != nil {
+		return fmt.Errorf("failed to remove previous build: %w", err)
+	}
+	if err := os.Rename(tmp, outDir); err != nil {
+		return fmt.Errorf("failed to write output directory: %w", err)
+	}
+	blogLogger.Info().Msgf("built %d files to %s", len(files), outDir)
+	return nil
+}
+
+// checkOutDir refuses output directories that would take the sources with them
+// when the previous build is replaced
+func checkOutDir(cfg *Config, outDir string) error {
+	if outDir == "" {
+		return errors.New("output directory must be specified")
+	}
+	out, err := filepath.Abs(outDir)
+	if err != nil {
+		return fmt.Errorf("invalid output directory: %w", err)
+	}
+	for _, src := range []string{cfg.ContentDir, cfg.WebDir, imageDir(cfg)} {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return fmt.Errorf("invalid source directory: %w", err)
+		}
+		if rel, err := filepath.Rel(out, abs); err == nil && filepath.IsLocal(rel) {
+			return fmt.Errorf("output directory %s contains %s", outDir, src)
+		}
+	}
+	return nil
+}
+
+// routeFile is the file a route is written to, false for routes that are not
+func routeFile(route string) (string, bool) {
+	p := path.Clean(route)
+	switch {
+	case p == "/":
+		return "index.html", true
+	case p == "/content" || p == "/tags":
+		return p[1:] + "/index.html", true
+	case p == "/feed":
+		return "feed/index.xml", true
+	case strings.HasPrefix(p, "/feed/tags/"):
+		return p[1:] + ".xml", true
+	case strings.HasPrefix(p, imageURLPrefix):
+		return p[1:], true
+	case strings.HasPrefix(p, "/article/"), strings.HasPrefix(p, "/series/"), strings.HasPrefix(p, "/tags/"):
+		return p[1:] + "/index.html", true
+	case path.Ext(p) != "":
+		return p[1:], true
+	}
+	return "", false
+}
+
+// siteFiles is every file of the static site by slash separated path
+func (bm *BlogManager) siteFiles() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	add := func(route string, body []byte) error {
+		name, ok := routeFile(route)
+		if !ok || !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("cannot write route %s", route)
+		}
+		files[name] = body
+		return nil
+	}
+
+	if err := bm.addWebFiles(files); err != nil {
+		return nil, err
+	}
+
+	bm.articleMutex.RLock()
+	routes := map[string][]byte{
+		"/":               bm.HomePage,
+		"/content/":       bm.HTMLList,
+		"/tags/":          bm.TagList,
+		"/feed/":          bm.RSSFeed,
+		"/feed/atom.xml":  bm.AtomFeed,
+		"/feed/feed.json": bm.JSONFeed,
+		"/sitemap.xml":    bm.SiteMap,
+		"/robots.txt":     []byte(robotsTxt),
+	}
+	for slug, arti := range bm.Articles {
+		routes["/article/"+slug] = arti.Content
+	}
+	for tag, list := range bm.TagArticleLists {
+		routes["/tags/"+tag] = list
+	}
+	for tag, feed := range bm.TagRSSFeeds {
+		routes["/feed/tags/"+tag] = feed
+	}
+	for slug, page := range bm.SeriesPages {
+		routes["/series/"+slug] = page
+	}
+	images := bm.servedImages
+	bm.articleMutex.RUnlock()
+
+	for route, body := range routes {
+		if err := add(route, body); err != nil {
+			return nil, err
+		}
+	}
+	css, err := HighlightCSS(bm.Config.HighlightStyle)
+	if err != nil {
+		return nil, err
+	}
+	if err := add(highlightCSSPath, css); err != nil {
+		return nil, err
+	}
+	if err := bm.addImageFiles(files, images); err != nil {
+		return nil, err
+	}
+
+	for name, body := range files {
+		if strings.HasSuffix(name, ".html") {
+			files[name] = relativeLinks(body, linkBase(name), files)
+		}
+	}
+	files[notFoundPage] = bm.Theme().ErrorPage(404, "page not found")
+	return files, nil
+}
+
+// addWebFiles copies the static assets the server answers / with
+func (bm *BlogManager) addWebFiles(files map[string][]byte) error {
+	err := filepath.WalkDir(bm.Config.WebDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(bm.Config.WebDir, p)
+		if err != nil {
+			return err
+		}
+		body, err := os.ReadFile(p) // #nosec G304 -- assets shipped with the server
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = body
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy web assets: %w", err)
+	}
+	return nil
+}
+
+// addImageFiles writes what ImageHandler serves: the processed files, source names
+// as their metadata free original and everything else from ContentDir/images
+func (bm *BlogManager) addImageFiles(files map[string][]byte, images imageSet) error {
+	dir := imageDir(bm.Config)
+	read := func(name, src string) error {
+		body, err := os.ReadFile(src) // #nosec G304 -- images are from our own git repo
+		if err != nil {
+			return fmt.Errorf("failed to read image: %w", err)
+		}
+		files[strings.TrimPrefix(imageURLPrefix, "/")+name] = body
+		return nil
+	}
+
+	for name, img := range images {
+		for _, v := range img.files() {
+			if err := read(v.Name, filepath.Join(dir, v.Name)); err != nil {
+				return err
+			}
+		}
+		if err := read(name, filepath.Join(dir, img.Original.Name)); err != nil {
+			return err
+		}
+	}
+
+	srcDir := filepath.Join(bm.Config.ContentDir, "images")
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if _, processed := images[name]; processed {
+			return nil
+		}
+		return read(name, p)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to copy images: %w", err)
+	}
+	return nil
+}
+
+// linkBase is the directory links in a file resolve against. the list and tag
+// fragments are swapped into the home page by htmx so theirs resolve from the root
+func linkBase(name string) string {
+	if strings.HasPrefix(name, "content/") || strings.HasPrefix(name, "tags/") {
+		return "."
+	}
+	return path.Dir(name)
+}
+
+// relativeLinks points root relative links to written files at them relative to
+// base. links to anything else, like search, are left alone
+func relativeLinks(body []byte, base string, files map[string][]byte) []byte {
+	return linkAttrRe.ReplaceAllFunc(body, func(attr []byte) []byte {
+		m := linkAttrRe.FindSubmatch(attr)
+		value := string(m[2])
+		if string(m[1]) == ` srcset="` {
+			candidates := strings.Split(value, ", ")
+			for i, c := range candidates {
+				u, w, _ := strings.Cut(c, " ")
+				candidates[i] = strings.TrimSpace(relativeLink(u, base, files) + " " + w)
+			}
+			value = strings.Join(candidates, ", ")
+		} else {
+			value = relativeLink(value, base, files)
+		}
+		return []byte(string(m[1]) + value + string(m[3]))
+	})
+}
+
+func relativeLink(link, base string, files map[string][]byte) string {
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	name, ok := routeFile(u.Path)
+	if _, written := files[name]; !ok || !written {
+		return link
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(base), filepath.FromSlash(name))
+	if err != nil {
+		return link
+	}
+	u.Path = filepath.ToSlash(rel)
+	return u.String()
+}
@@ -0,0 +1,144 @@
+package blog
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildConfig is LocalOnly content with a tagged article, an image and web assets
+func buildConfig(t *testing.T) *Config {
+	root := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = filepath.Join(root, "content")
+	cfg.WebDir = filepath.Join(root, "web")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "images"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, themeDir), 0o755))
+	require.NoError(t, os.MkdirAll(cfg.WebDir, 0o755))
+	files := map[string]string{
+		"hello.md": "---\ntitle: Hello\ndate: 2024-01-02\ntags: [go]\n---\n" +
+			"See [the other post](/article/other#end) and ![photo](images/photo.jpg)",
+		"other.md":     "---\ntitle: Other\ndate: 2024-01-01\n---\nThe other post.",
+		"images/a.gif": "GIF89a",
+		"../web/a.css": "body{}",
+		"theme/home.html": `{{define "styles"}}<link href="/a.css">{{end}}` +
+			`{{define "content"}}<ul hx-get="/content"></ul><input hx-get="/search">{{end}}`,
+	}
+	for name, body := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, name), []byte(body), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "photo.jpg"), testJPEG(t, 600, 300), 0o644))
+
+	// LocalOnly articles are dated by their files, pinned so every build matches
+	err := filepath.WalkDir(cfg.ContentDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, day(3), day(3))
+	})
+	require.NoError(t, err)
+	return cfg
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	tree := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := os.ReadFile(p)
+		rel, _ := filepath.Rel(dir, p)
+		tree[filepath.ToSlash(rel)] = string(body)
+		return err
+	})
+	require.NoError(t, err)
+	return tree
+}
+
+func TestBuild(t *testing.T) {
+	cfg := buildConfig(t)
+	out := filepath.Join(t.TempDir(), "public")
+	require.NoError(t, Build(cfg, out))
+	site := readTree(t, out)
+
+	for _, name := range []string{
+		"index.html", "a.css", "404.html", "robots.txt", "sitemap.xml", "highlight.css",
+		"content/index.html", "tags/index.html", "tags/go/index.html",
+		"feed/index.xml", "feed/atom.xml", "feed/feed.json", "feed/tags/go.xml",
+		"article/hello/index.html", "article/other/index.html",
+		"article/images/a.gif", "article/images/photo.jpg",
+	} {
+		require.Contains(t, site, name)
+	}
+
+	require.Contains(t, site["index.html"], `<link href="a.css">`, "the home page is rendered from the theme")
+	require.Contains(t, site["index.html"], `<ul hx-get="content/index.html"></ul><input hx-get="/search">`,
+		"links to written files are relative, the rest are left alone")
+	require.Contains(t, site["content/index.html"], `href="article/hello/index.html"`, "fragments link from the home page")
+	require.Contains(t, site["tags/index.html"], `hx-get="tags/go/index.html"`)
+
+	hello := site["article/hello/index.html"]
+	require.Contains(t, hello, `href="../other/index.html#end"`)
+	require.Contains(t, hello, `href="../../highlight.css"`)
+	require.Contains(t, hello, `srcset="../images/`)
+	require.Contains(t, site["404.html"], `href="/article.css"`, "served at any depth")
+
+	// a second build of the same content is identical and replaces the first
+	require.NoError(t, os.WriteFile(filepath.Join(out, "leftover.html"), nil, 0o644))
+	require.NoError(t, Build(buildConfig(t), out))
+	require.Equal(t, site, readTree(t, out))
+}
+
+func TestBuildPublishesNothing(t *testing.T) {
+	cfg := buildConfig(t)
+	cfg.IMAGECACHE = true
+	store := &memImageStore{}
+	out := filepath.Join(t.TempDir(), "public")
+	require.NoError(t, buildSite(cfg, out, store))
+
+	require.Empty(t, store.puts, "a static build never uploads images")
+	require.True(t, cfg.IMAGECACHE, "the caller's config is left alone")
+	hello, err := os.ReadFile(filepath.Join(out, "article", "hello", "index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(hello), `srcset="../images/`, "images are linked from the mirror")
+	require.NotContains(t, string(hello), cfg.ImageBucketURL)
+}
+
+func TestBuildOutDir(t *testing.T) {
+	cfg := buildConfig(t)
+	require.Error(t, Build(cfg, ""))
+	require.ErrorContains(t, Build(cfg, filepath.Dir(cfg.ContentDir)), "contains")
+	require.ErrorContains(t, Build(cfg, cfg.WebDir), "contains")
+	require.DirExists(t, cfg.ContentDir)
+}
+
+func TestRouteFile(t *testing.T) {
+	tests := []struct {
+		route string
+		want  string
+	}{
+		{"/", "index.html"},
+		{"/content", "content/index.html"},
+		{"/content/", "content/index.html"},
+		{"/tags/go", "tags/go/index.html"},
+		{"/feed/", "feed/index.xml"},
+		{"/feed/tags/go", "feed/tags/go.xml"},
+		{"/feed/atom.xml", "feed/atom.xml"},
+		{"/article/hello", "article/hello/index.html"},
+		{"/article/images/a.png", "article/images/a.png"},
+		{"/series/intro", "series/intro/index.html"},
+		{"/styles.css", "styles.css"},
+		{"/search", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := routeFile(tt.route)
+		require.Equal(t, tt.want != "", ok, tt.route)
+		require.Equal(t, tt.want, got, tt.route)
+	}
+}
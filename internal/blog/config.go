@@ -3,15 +3,19 @@ package blog
 import (
 	"context"
 	"crypto/tls"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 type ConfigOption func(*Config) error
@@ -34,12 +38,36 @@ type Config struct {
 	ProfileFlag         bool   // indicates if profiling is enabled
 	ProfilePath         string // where to write profiling report
 	CostTrackingEnabled bool   // CostTrackingEnabled enables AWS cost tracking via Cost Explorer API
+	FeedItems           int    // FeedItems caps the number of newest articles in each feed
+	WebhookSecret       string // WebhookSecret enables the push webhook and is the HMAC key deliveries are signed with
+	PollInterval        int    // PollInterval is the seconds between checks of the remote for new commits, 0 disables polling
+	ContentBranch       string // ContentBranch is the branch of the content repo that is served
+	PreviewBranches     string // PreviewBranches is a comma separated allow list of branches served under /preview/{branch}/
+	PreviewToken        string // PreviewToken is the shared secret reviewers need to read preview branches
+	SnapshotHistory     int    // SnapshotHistory is how many built content snapshots are kept for rollback
+	AdminToken          string // AdminToken enables the /admin/ endpoints and is the bearer token they require
+	ValidationPolicy    string // ValidationPolicy is "warn" to publish content with validation issues or "reject" to keep the previous content
+	MarkdownEngine      string // MarkdownEngine is "blackfriday" or "goldmark" for github flavoured markdown
+	HighlightStyle      string // HighlightStyle is the chroma style /highlight.css is generated from
+	WebDir              string // WebDir holds the static assets served from /
+	ImageDir            string // ImageDir is where processed images are cached, defaults to ContentDir with an .images suffix
+	ImageBucket         string // ImageBucket is the bucket images are published to when IMAGECACHE is on
+	ImageBucketURL      string // ImageBucketURL is the public url of ImageBucket that pages link images to
+	ImageBucketRegion   string // ImageBucketRegion is the region of ImageBucket
+	ImageBucketEndpoint string // ImageBucketEndpoint overrides the s3 endpoint for s3 compatible stores
+	ControlSocket       string // ControlSocket is the unix socket a running server takes commands like reload on, empty disables it
+	TOCThreshold        int    // TOCThreshold adds a table of contents to articles with more headings than this, 0 only uses [[toc]] markers
+	DevMode             bool   // DevMode rebuilds LocalOnly content when ContentDir changes and reloads open article pages
+	DevWatch            string // DevWatch is "notify" for file system events with a polling fallback or "poll" to always poll
+
+	previewBranch string // set on the config of a preview, whose images are served under its own path
 }
 
 func DefaultConfig() *Config {
 	return &Config{
 		ServerPort:          "8080",
 		ContentDir:          "content",
+		WebDir:              "/web",
 		KeyPrivPath:         filepath.Join(os.TempDir(), "blog-repo-key"),
 		LocalOnly:           false,
 		HTTPSOn:             false,
@@ -47,9 +75,47 @@ func DefaultConfig() *Config {
 		ExportMetrics:       false,
 		ProfileFlag:         false,
 		CostTrackingEnabled: false,
+		FeedItems:           20,
+		ContentBranch:       "main",
+		SnapshotHistory:     5,
+		ValidationPolicy:    ValidationWarn,
+		MarkdownEngine:      EngineBlackfriday,
+		HighlightStyle:      "github",
+		ImageBucket:         "jakeblog-blog-image-cache",
+		ImageBucketURL:      "https://jakeblog-blog-image-cache.s3.us-east-1.amazonaws.com/",
+		ImageBucketRegion:   "us-east-1",
+		ControlSocket:       filepath.Join(os.TempDir(), "jake-blog.sock"),
+		DevWatch:            WatchNotify,
 	}
 }
 
+const (
+	minPreviewTokenLen = 16
+	minAdminTokenLen   = 32
+)
+
+// PreviewBranchList splits PreviewBranches dropping blanks and duplicates
+func (c *Config) PreviewBranchList() []string {
+	var branches []string
+	seen := make(map[string]bool)
+	for _, branch := range strings.Split(c.PreviewBranches, ",") {
+		branch = strings.TrimSpace(branch)
+		if branch == "" || seen[branch] {
+			continue
+		}
+		seen[branch] = true
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+func validBranch(branch string) error {
+	if branch == "" {
+		return fmt.Errorf("branch name is empty")
+	}
+	return plumbing.NewBranchReferenceName(branch).Validate()
+}
+
 func NewConfig(opts ...ConfigOption) (*Config, error) {
 	cfg := DefaultConfig()
 
@@ -66,11 +132,24 @@ func NewConfig(opts ...ConfigOption) (*Config, error) {
 	return cfg, nil
 }
 
+// loadConfig applies the environment and opts without validating, for commands
+// that only need a few settings of an otherwise configured server
+func loadConfig(envPrefix string, opts ...ConfigOption) (*Config, error) {
+	cfg := DefaultConfig()
+	for _, opt := range append([]ConfigOption{withEnvironment(envPrefix)}, opts...) {
+		if err := opt(cfg); err != nil {
+			return nil, fmt.Errorf("failed to apply config option: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
 func (c *Config) Validate() error {
 	required := map[string]string{
 		"ServerPort": c.ServerPort,
 		"RepoURL":    c.RepoURL,
 		"ContentDir": c.ContentDir,
+		"WebDir":     c.WebDir,
 		"Env":        c.Env,
 	}
 
@@ -99,6 +178,77 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.FeedItems < 1 {
+		return fmt.Errorf("feed items must be at least 1")
+	}
+
+	if c.PollInterval < 0 {
+		return fmt.Errorf("poll interval must not be negative")
+	}
+
+	if c.ValidationPolicy != ValidationWarn && c.ValidationPolicy != ValidationReject {
+		return fmt.Errorf("validation policy must be %q or %q", ValidationWarn, ValidationReject)
+	}
+
+	if c.MarkdownEngine != EngineBlackfriday && c.MarkdownEngine != EngineGoldmark {
+		return fmt.Errorf("markdown engine must be %q or %q", EngineBlackfriday, EngineGoldmark)
+	}
+
+	if !validHighlightStyle(c.HighlightStyle) {
+		return fmt.Errorf("unknown highlight style %q", c.HighlightStyle)
+	}
+
+	if c.TOCThreshold < 0 {
+		return fmt.Errorf("toc threshold must not be negative")
+	}
+
+	if c.IMAGECACHE {
+		if c.ImageBucket == "" {
+			return fmt.Errorf("image bucket must be specified when the image cache is enabled")
+		}
+		if _, err := imageBucketOrigin(c.ImageBucketURL); err != nil {
+			return fmt.Errorf("invalid image bucket url: %w", err)
+		}
+	}
+
+	if c.DevMode && !c.LocalOnly {
+		return fmt.Errorf("dev mode watches local content and requires LocalOnly")
+	}
+
+	if c.DevWatch != WatchNotify && c.DevWatch != WatchPoll {
+		return fmt.Errorf("dev watch must be %q or %q", WatchNotify, WatchPoll)
+	}
+
+	if c.SnapshotHistory < 1 {
+		return fmt.Errorf("snapshot history must be at least 1")
+	}
+
+	if c.AdminToken != "" && len(c.AdminToken) < minAdminTokenLen {
+		return fmt.Errorf("admin token must be at least %d characters", minAdminTokenLen)
+	}
+
+	if err := validBranch(c.ContentBranch); err != nil {
+		return fmt.Errorf("invalid content branch: %w", err)
+	}
+
+	if branches := c.PreviewBranchList(); len(branches) > 0 {
+		if c.LocalOnly {
+			return fmt.Errorf("preview branches require a content repo and cannot be used with LocalOnly")
+		}
+		if len(c.PreviewToken) < minPreviewTokenLen {
+			return fmt.Errorf("preview token must be at least %d characters when preview branches are set", minPreviewTokenLen)
+		}
+		for _, branch := range branches {
+			if err := validBranch(branch); err != nil {
+				return fmt.Errorf("invalid preview branch: %w", err)
+			}
+			// the branch is a single path segment of the preview url
+			if strings.Contains(branch, "/") {
+				return fmt.Errorf("invalid preview branch %q: must not contain /", branch)
+			}
+		}
+	}
+
 	if c.ExportMetrics {
 		if c.MetricOTLP == "" {
 			return fmt.Errorf("grpc otlp reciever must be specified when metric exporting is enabled")
@@ -123,43 +273,91 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// configFields maps every env var, without its prefix, to the field it sets
+func configFields(c *Config) (map[string]*string, map[string]*bool, map[string]*int) {
+	envVars := map[string]*string{
+		"SERVER_PORT":           &c.ServerPort,
+		"REPO_URL":              &c.RepoURL,
+		"CONTENT_DIR":           &c.ContentDir,
+		"REPO_PRIV_KEY":         &c.RepoKeyPriv,
+		"REPO_PRIV_KEY_PATH":    &c.KeyPrivPath,
+		"REPO_PASS":             &c.RepoPass,
+		"HTTPSCRT":              &c.HTTPSCRT,
+		"HTTPSKEY":              &c.HTTPSKey,
+		"METRIC_OTLP_RECIEVER":  &c.MetricOTLP,
+		"ENVMNT":                &c.Env,
+		"PROFILING_REPORT":      &c.ProfilePath,
+		"WEBHOOK_SECRET":        &c.WebhookSecret,
+		"CONTENT_BRANCH":        &c.ContentBranch,
+		"PREVIEW_BRANCHES":      &c.PreviewBranches,
+		"PREVIEW_TOKEN":         &c.PreviewToken,
+		"ADMIN_TOKEN":           &c.AdminToken,
+		"VALIDATION_POLICY":     &c.ValidationPolicy,
+		"MARKDOWN_ENGINE":       &c.MarkdownEngine,
+		"HIGHLIGHT_STYLE":       &c.HighlightStyle,
+		"WEB_DIR":               &c.WebDir,
+		"IMAGE_DIR":             &c.ImageDir,
+		"IMAGE_BUCKET":          &c.ImageBucket,
+		"IMAGE_BUCKET_URL":      &c.ImageBucketURL,
+		"IMAGE_BUCKET_REGION":   &c.ImageBucketRegion,
+		"IMAGE_BUCKET_ENDPOINT": &c.ImageBucketEndpoint,
+		"CONTROL_SOCKET":        &c.ControlSocket,
+		"DEV_WATCH":             &c.DevWatch,
+	}
+	envFlags := map[string]*bool{
+		"LOCAL_ONLY":            &c.LocalOnly,
+		"HTTPS_ON":              &c.HTTPSOn,
+		"IMAGECACHE":            &c.IMAGECACHE,
+		"EXPORT_METRICS":        &c.ExportMetrics,
+		"PROFILING_ENABLED":     &c.ProfileFlag,
+		"COST_TRACKING_ENABLED": &c.CostTrackingEnabled,
+		"DEV_MODE":              &c.DevMode,
+	}
+	envInts := map[string]*int{
+		"FEED_ITEMS":       &c.FeedItems,
+		"POLL_INTERVAL":    &c.PollInterval,
+		"TOC_THRESHOLD":    &c.TOCThreshold,
+		"SNAPSHOT_HISTORY": &c.SnapshotHistory,
+	}
+	return envVars, envFlags, envInts
+}
+
 // load config from env var
 func withEnvironment(prefix string) ConfigOption {
+	return withValues(
+		func(env string) string { return os.Getenv(prefix + env) },
+		func(env string) string { return prefix + env },
+	)
+}
+
+// withValues sets the fields of every env var with a non empty value. name is
+// what errors call the value
+func withValues(value func(env string) string, name func(env string) string) ConfigOption {
 	return func(c *Config) error {
-		envVars := map[string]*string{
-			"SERVER_PORT":          &c.ServerPort,
-			"REPO_URL":             &c.RepoURL,
-			"CONTENT_DIR":          &c.ContentDir,
-			"REPO_PRIV_KEY":        &c.RepoKeyPriv,
-			"REPO_PRIV_KEY_PATH":   &c.KeyPrivPath,
-			"REPO_PASS":            &c.RepoPass,
-			"HTTPSCRT":             &c.HTTPSCRT,
-			"HTTPSKEY":             &c.HTTPSKey,
-			"METRIC_OTLP_RECIEVER": &c.MetricOTLP,
-			"ENVMNT":               &c.Env,
-			"PROFILING_REPORT":     &c.ProfilePath,
-		}
-		envFlags := map[string]*bool{
-			"LOCAL_ONLY":            &c.LocalOnly,
-			"HTTPS_ON":              &c.HTTPSOn,
-			"IMAGECACHE":            &c.IMAGECACHE,
-			"EXPORT_METRICS":        &c.ExportMetrics,
-			"PROFILING_ENABLED":     &c.ProfileFlag,
-			"COST_TRACKING_ENABLED": &c.CostTrackingEnabled,
-		}
+		envVars, envFlags, envInts := configFields(c)
 		for env, ptr := range envVars {
-			if value := os.Getenv(prefix + env); value != "" {
+			if value := value(env); value != "" {
 				*ptr = value
 			}
 		}
 
 		for env, ptr := range envFlags {
-			if value := os.Getenv(prefix + env); value != "" {
-				if value == "true" {
-					*ptr = true
-				} else {
-					*ptr = false
+			if value := value(env); value != "" {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("invalid %s: %w", name(env), err)
+				}
+				*ptr = b
+			}
+		}
+
+		for env, ptr := range envInts {
+			if value := value(env); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return fmt.Errorf("invalid %s: %w", name(env), err)
 				}
+				*ptr = n
 			}
 		}
 
@@ -167,6 +365,43 @@ func withEnvironment(prefix string) ConfigOption {
 	}
 }
 
+// ConfigFlags defines a flag on fs for every env var, named like it in lower case
+// with dashes so -content-dir overrides BLOG_CONTENT_DIR. the returned option
+// applies the flags that were set and goes after the environment
+func ConfigFlags(fs *flag.FlagSet, envPrefix string) ConfigOption {
+	values := make(map[string]string)
+	envVars, envFlags, envInts := configFields(&Config{})
+	for env := range envVars {
+		fs.Func(configFlagName(env), "overrides "+envPrefix+env, func(v string) error {
+			values[env] = v
+			return nil
+		})
+	}
+	for env := range envFlags {
+		fs.BoolFunc(configFlagName(env), "overrides "+envPrefix+env, func(v string) error {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return err
+			}
+			values[env] = v
+			return nil
+		})
+	}
+	for env := range envInts {
+		fs.Func(configFlagName(env), "overrides "+envPrefix+env, func(v string) error {
+			values[env] = v
+			return nil
+		})
+	}
+	return withValues(
+		func(env string) string { return values[env] },
+		func(env string) string { return "-" + configFlagName(env) },
+	)
+}
+
+func configFlagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
 // Write private key to disk if provided
 func (c *Config) initializePrivateKey() error {
 	if c.RepoKeyPriv == "" {
@@ -0,0 +1,50 @@
+package blog
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFlags(t *testing.T) {
+	t.Setenv("CFG_CONTENT_DIR", "from-env")
+	t.Setenv("CFG_SERVER_PORT", "9000")
+	t.Setenv("CFG_LOCAL_ONLY", "true")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := ConfigFlags(fs, "CFG_")
+	require.NoError(t, fs.Parse([]string{"-content-dir", "from-flag", "-local-only=false", "-feed-items", "5", "-imagecache"}))
+
+	cfg, err := loadConfig("CFG_", flags)
+	require.NoError(t, err)
+	require.Equal(t, "from-flag", cfg.ContentDir, "flags override the environment")
+	require.Equal(t, "9000", cfg.ServerPort, "unset flags leave it alone")
+	require.False(t, cfg.LocalOnly)
+	require.True(t, cfg.IMAGECACHE)
+	require.Equal(t, 5, cfg.FeedItems)
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags = ConfigFlags(fs, "CFG_")
+	require.Error(t, fs.Parse([]string{"-https-on=maybe"}))
+	require.NoError(t, fs.Parse([]string{"-feed-items", "many"}))
+	_, err = loadConfig("CFG_", flags)
+	require.ErrorContains(t, err, "invalid -feed-items")
+
+	// bools parse the same way from flags and the environment
+	t.Setenv("CFG_LOCAL_ONLY", "0")
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	flags = ConfigFlags(fs, "CFG_")
+	require.NoError(t, fs.Parse([]string{"-imagecache=1", "-https-on=TRUE"}))
+	cfg, err = loadConfig("CFG_", flags)
+	require.NoError(t, err)
+	require.True(t, cfg.IMAGECACHE)
+	require.True(t, cfg.HTTPSOn)
+	require.False(t, cfg.LocalOnly)
+
+	t.Setenv("CFG_LOCAL_ONLY", "yes")
+	_, err = loadConfig("CFG_")
+	require.ErrorContains(t, err, "invalid CFG_LOCAL_ONLY")
+}
@@ -0,0 +1,124 @@
+package blog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// the control socket lets the cli drive a running server on the same host. a
+// client writes one command per connection and reads one reply line. access is
+// limited by the socket's file permissions
+
+const (
+	controlReload  = "reload"
+	controlOK      = "ok"
+	controlTimeout = 5 * time.Second
+)
+
+// listenForControl serves the control socket until ctx is done
+func (bs *BlogServer) listenForControl(ctx context.Context) error {
+	path := bs.cfg.ControlSocket
+	if path == "" {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("control socket %s is in use by another server", path)
+	}
+	// left behind by a server that did not shut down
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = l.Close()
+		return fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = l.Close() // also removes the socket file
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go bs.handleControl(conn)
+		}
+	}()
+	blogLogger.Info().Msgf("control socket listening on %s", path)
+	return nil
+}
+
+func (bs *BlogServer) handleControl(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	line, err := bufio.NewReader(io.LimitReader(conn, 64)).ReadString('\n')
+	if err != nil {
+		blogLogger.Warn().Msgf("failed to read control command: %v", err)
+		return
+	}
+
+	reply := controlOK
+	switch command := strings.TrimSpace(line); command {
+	case controlReload:
+		blogLogger.Info().Msg("reload requested on control socket")
+		bs.bm.TriggerUpdate()
+		for _, preview := range bs.previews {
+			preview.TriggerUpdate()
+		}
+	default:
+		reply = fmt.Sprintf("unknown command %q", command)
+	}
+	if _, err := io.WriteString(conn, reply+"\n"); err != nil {
+		blogLogger.Warn().Msgf("failed to reply on control socket: %v", err)
+	}
+}
+
+// sendControl sends command to the server on the control socket at path
+func sendControl(path, command string) error {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return fmt.Errorf("no server listening on %s: %w", path, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if _, err := io.WriteString(conn, command+"\n"); err != nil {
+		return fmt.Errorf("failed to send %s: %w", command, err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read reply: %w", err)
+	}
+	if reply = strings.TrimSpace(reply); reply != controlOK {
+		return fmt.Errorf("server refused %s: %s", command, reply)
+	}
+	return nil
+}
+
+// Reload asks the server on the control socket to update its content
+func Reload(envPrefix string, opts ...ConfigOption) error {
+	cfg, err := loadConfig(envPrefix, opts...)
+	if err != nil {
+		return err
+	}
+	if cfg.ControlSocket == "" {
+		return errors.New("control socket is disabled")
+	}
+	return sendControl(cfg.ControlSocket, controlReload)
+}
@@ -0,0 +1,47 @@
+package blog
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ctl") // short enough for a socket path
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := DefaultConfig()
+	cfg.ControlSocket = filepath.Join(dir, "ctl.sock")
+	bs := &BlogServer{cfg: *cfg, bm: NewBlogManager(cfg)}
+
+	// a socket left behind by a crashed server is replaced
+	stale, err := net.Listen("unix", cfg.ControlSocket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, bs.listenForControl(ctx))
+	info, err := os.Stat(cfg.ControlSocket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	other := &BlogServer{cfg: *cfg, bm: NewBlogManager(cfg)}
+	require.ErrorContains(t, other.listenForControl(ctx), "in use")
+
+	require.NoError(t, sendControl(cfg.ControlSocket, controlReload))
+	require.Len(t, bs.bm.updateChan, 1, "reload triggers an update")
+	require.ErrorContains(t, sendControl(cfg.ControlSocket, "explode"), "unknown command")
+
+	t.Setenv("CTL_CONTROL_SOCKET", cfg.ControlSocket)
+	require.NoError(t, Reload("CTL_"))
+
+	t.Setenv("CTL_CONTROL_SOCKET", filepath.Join(dir, "missing.sock"))
+	require.ErrorContains(t, Reload("CTL_"), "no server listening")
+}
@@ -0,0 +1,338 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dev mode is for writing against LocalOnly content. ContentDir is watched and a
+// burst of saves becomes one content update, after which open article and home
+// pages are told to reload over server sent events. file system events are used
+// where they can be watched and ContentDir is polled where they cannot. mounts
+// that accept watches but never deliver events, like some container bind mounts,
+// are not detected and need DevWatch set to poll
+
+const (
+	WatchNotify = "notify"
+	WatchPoll   = "poll"
+
+	devDebounce     = 250 * time.Millisecond // quiet period before a burst of changes is built
+	devPollInterval = time.Second
+
+	devReloadPath       = "/dev/reload"
+	devReloadScriptPath = "/dev/reload.js"
+	devReloadScript     = `new EventSource("` + devReloadPath + `").addEventListener("reload", function () { location.reload(); });` + "\n"
+	devReloadTag        = `<script src="` + devReloadScriptPath + `"></script>`
+)
+
+// watchContent triggers an update once changes to ContentDir settle
+func (bm *BlogManager) watchContent(ctx context.Context) {
+	if !bm.Config.DevMode {
+		return
+	}
+	w := newContentWatcher(bm.Config)
+	if bm.Config.DevWatch == WatchPoll {
+		go w.poll(ctx, devPollInterval)
+	} else if err := w.notify(ctx); err != nil {
+		managerLogger.Warn().Msgf("polling %s for changes: file system events unavailable: %v", w.root, err)
+		go w.poll(ctx, devPollInterval)
+	}
+	go debounce(ctx, w.changes, devDebounce, bm.TriggerUpdate)
+	managerLogger.Info().Msgf("dev mode watching %s", w.root)
+}
+
+// debounce calls fn once nothing has arrived on changes for wait
+func debounce(ctx context.Context, changes <-chan struct{}, wait time.Duration, fn func()) {
+	timer := time.NewTimer(wait)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+			timer.Reset(wait)
+		case <-timer.C:
+			fn()
+		}
+	}
+}
+
+type contentWatcher struct {
+	root    string
+	skip    string        // processed image cache, written by every update
+	changes chan struct{} // buffered so a burst is one pending signal
+}
+
+func newContentWatcher(cfg *Config) *contentWatcher {
+	return &contentWatcher{
+		root:    filepath.Clean(cfg.ContentDir),
+		skip:    filepath.Clean(imageDir(cfg)),
+		changes: make(chan struct{}, 1),
+	}
+}
+
+func (w *contentWatcher) changed() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// ignored is true for hidden files and directories like .git, editor backups and
+// the image cache when it is inside ContentDir
+func (w *contentWatcher) ignored(p string) bool {
+	if p = filepath.Clean(p); p == w.skip {
+		return true
+	}
+	if rel, err := filepath.Rel(w.skip, p); err == nil && filepath.IsLocal(rel) {
+		return true
+	}
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil || rel == "." {
+		return err != nil
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, "~") {
+			return true
+		}
+	}
+	return false
+}
+
+// notify watches every directory under the root for file system events. new
+// directories are watched as they are created
+func (w *contentWatcher) notify(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.watchDirs(fsw, w.root); err != nil {
+		_ = fsw.Close()
+		return err
+	}
+
+	go func() {
+		defer fsw.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if w.ignored(event.Name) {
+					continue
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := w.watchDirs(fsw, event.Name); err != nil {
+							managerLogger.Warn().Msgf("failed to watch %s: %v", event.Name, err)
+						}
+					}
+				}
+				w.changed()
+			case err, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+				// events may have been dropped so rebuild anyway
+				managerLogger.Warn().Msgf("content watcher error: %v", err)
+				w.changed()
+			}
+		}
+	}()
+	return nil
+}
+
+func (w *contentWatcher) watchDirs(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if w.ignored(p) {
+			return filepath.SkipDir
+		}
+		return fsw.Add(p)
+	})
+}
+
+// fileStamp changes when a file is written
+type fileStamp struct {
+	mod  int64
+	size int64
+}
+
+// poll compares a listing of the root every interval
+func (w *contentWatcher) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := w.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		next := w.snapshot()
+		if !maps.Equal(last, next) {
+			w.changed()
+		}
+		last = next
+	}
+}
+
+func (w *contentWatcher) snapshot() map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	err := filepath.WalkDir(w.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if w.ignored(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed since it was listed
+		}
+		files[p] = fileStamp{mod: info.ModTime().UnixNano(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		managerLogger.Warn().Msgf("failed to scan %s for changes: %v", w.root, err)
+	}
+	return files
+}
+
+// reloadHub fans a reload out to every open dev mode page
+type reloadHub struct {
+	mu     sync.Mutex
+	subs   map[chan struct{}]struct{}
+	closed bool
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{subs: make(map[chan struct{}]struct{})}
+}
+
+// subscribe returns a channel that receives a value after every update and is
+// closed with the hub, and the func that unsubscribes it
+func (h *reloadHub) subscribe() (<-chan struct{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *reloadHub) publish() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// close ends every subscription so open streams do not hold up shutdown
+func (h *reloadHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// withDevReload adds the reload script to an article or home page. inline scripts
+// are refused by the content security policy so it is loaded from the server
+func withDevReload(page []byte) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		i = len(page)
+	}
+	out := make([]byte, 0, len(page)+len(devReloadTag))
+	out = append(out, page[:i]...)
+	out = append(out, devReloadTag...)
+	return append(out, page[i:]...)
+}
+
+// DevReload streams a reload event after every successful content update
+func (s *Server) DevReload(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "DevReloadHandler.Process")
+	defer span.End()
+
+	rc := http.NewResponseController(w)
+	// the stream outlives the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		serverLogger.Warn().Msgf("reload stream limited by the write timeout: %v", err)
+	}
+	reloads, unsubscribe := s.bm.reloads.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		serverLogger.Error().Msgf("failed to open reload stream: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-reloads:
+			if !ok {
+				return
+			}
+			if _, err := io.WriteString(w, "event: reload\ndata: {}\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// DevReloadScript serves the script article pages reload with in dev mode
+func (s *Server) DevReloadScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := io.WriteString(w, devReloadScript); err != nil {
+		serverLogger.Error().Msgf("failed to write reload script: %v", err)
+	}
+}
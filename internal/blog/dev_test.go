@@ -0,0 +1,224 @@
+package blog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 1)
+	var calls atomic.Int32
+	go debounce(ctx, changes, 50*time.Millisecond, func() { calls.Add(1) })
+
+	for range 5 {
+		changes <- struct{}{}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, int32(1), calls.Load(), "a burst is one update")
+
+	changes <- struct{}{}
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestContentWatcher(t *testing.T) {
+	tests := []struct {
+		name  string
+		start func(context.Context, *contentWatcher) error
+	}{
+		{"notify", func(ctx context.Context, w *contentWatcher) error { return w.notify(ctx) }},
+		{"poll", func(ctx context.Context, w *contentWatcher) error {
+			go w.poll(ctx, 20*time.Millisecond)
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ContentDir = t.TempDir()
+			w := newContentWatcher(cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			require.NoError(t, tt.start(ctx, w))
+			time.Sleep(50 * time.Millisecond) // first poll snapshot
+
+			wait := func(msg string) {
+				select {
+				case <-w.changes:
+				case <-time.After(2 * time.Second):
+					t.Fatal(msg)
+				}
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "a.md"), []byte("# A"), 0o644))
+			wait("new file")
+
+			require.NoError(t, os.Mkdir(filepath.Join(cfg.ContentDir, "series"), 0o755))
+			time.Sleep(100 * time.Millisecond) // new directories are watched once seen
+			select {
+			case <-w.changes:
+			default:
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "series", "b.md"), []byte("# B"), 0o644))
+			wait("file in a new directory")
+
+			require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "a.md"), []byte("# A edited"), 0o644))
+			wait("edited file")
+		})
+	}
+}
+
+func TestContentWatcherIgnored(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	cfg.ImageDir = filepath.Join(cfg.ContentDir, "cache")
+	w := newContentWatcher(cfg)
+
+	tests := map[string]bool{
+		"":                    false,
+		"hello.md":            false,
+		"series/part.md":      false,
+		"images/photo.jpg":    false,
+		".git":                true,
+		".git/index":          true,
+		"series/.part.md.swp": true,
+		"hello.md~":           true,
+		"cache":               true,
+		"cache/abc.webp":      true,
+	}
+	for rel, want := range tests {
+		require.Equal(t, want, w.ignored(filepath.Join(cfg.ContentDir, rel)), rel)
+	}
+}
+
+func TestReloadHub(t *testing.T) {
+	hub := newReloadHub()
+	a, unsubscribe := hub.subscribe()
+	b, _ := hub.subscribe()
+
+	hub.publish()
+	hub.publish()
+	require.Len(t, a, 1, "pending reloads are not queued up")
+	require.Len(t, b, 1)
+	<-a
+
+	unsubscribe()
+	hub.publish()
+	_, ok := <-a
+	require.False(t, ok, "unsubscribed")
+
+	hub.close()
+	<-b
+	_, ok = <-b
+	require.False(t, ok, "closed with the hub")
+	c, _ := hub.subscribe()
+	_, ok = <-c
+	require.False(t, ok, "subscribing to a closed hub")
+}
+
+func TestDevReload(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.DevMode = true
+	bm := NewBlogManager(cfg)
+	bm.Articles["hello"] = Article{Slug: "hello", Content: []byte("<html><body><p>hi</p></body></html>")}
+	s := NewServer(bm, nil)
+	require.NotNil(t, s)
+	srv := httptest.NewServer(s.SetupRoutes())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/article/hello")
+	require.NoError(t, err)
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, `<html><body><p>hi</p>`+devReloadTag+`</body></html>`, string(page))
+
+	resp, err = http.Get(srv.URL + devReloadScriptPath)
+	require.NoError(t, err)
+	script, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Contains(t, string(script), devReloadPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+devReloadPath, nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	bm.reloads.publish()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: reload\n", line)
+
+	bm.reloads.close()
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err, "the stream ends when the server shuts down")
+}
+
+func TestDevReloadOff(t *testing.T) {
+	cfg := DefaultConfig()
+	bm := NewBlogManager(cfg)
+	bm.Articles["hello"] = Article{Slug: "hello", Content: []byte("<html><body></body></html>")}
+	s := NewServer(bm, nil)
+	require.NotNil(t, s)
+	srv := httptest.NewServer(s.SetupRoutes())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/article/hello")
+	require.NoError(t, err)
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotContains(t, string(page), devReloadScriptPath)
+}
+
+func TestDevModeConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		localOnly bool
+		devMode   bool
+		watch     string
+		wantErr   bool
+	}{
+		{"off", false, false, WatchNotify, false},
+		{"notify", true, true, WatchNotify, false},
+		{"poll", true, true, WatchPoll, false},
+		{"needs local content", false, true, WatchNotify, true},
+		{"unknown watch", true, true, "inotify", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.RepoURL = "git@example.com:blog.git"
+			cfg.Env = "test"
+			cfg.LocalOnly = tt.localOnly
+			cfg.DevMode = tt.devMode
+			cfg.DevWatch = tt.watch
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
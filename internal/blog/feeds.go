@@ -0,0 +1,300 @@
+package blog
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"time"
+)
+
+const (
+	siteURL         = "https://jake-henning.com"
+	siteTitle       = "Jacob Henning's Blog"
+	siteDescription = "The personal blog of Jacob Henning"
+	siteAuthor      = "Jacob Henning"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        rssGUID  `xml:"guid"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated,omitempty"` // left out of an empty feed rather than dated year one
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+// last time the article changed; front matter updated or the publish date
+func articleUpdated(arti *Article) time.Time {
+	if arti.Updated.After(arti.Date) {
+		return arti.Updated
+	}
+	return arti.Date
+}
+
+// plain text description for feeds: the summary, which falls back to the first
+// paragraph when front matter has none
+func articleDescription(arti *Article) string {
+	if arti.Summary != "" {
+		return arti.Summary
+	}
+	return firstParagraph(arti.Body)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// articles must be sorted newest first and already capped to the feed size
+func buildRSSFeed(title, selfURL string, articles []Article) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        siteURL,
+			Description: siteDescription,
+			AtomLink:    atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+			Items:       make([]rssItem, 0, len(articles)),
+		},
+	}
+
+	var lastBuild time.Time
+	for i := range articles {
+		arti := &articles[i]
+		if updated := articleUpdated(arti); updated.After(lastBuild) {
+			lastBuild = updated
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       arti.Title,
+			Link:        siteURL + arti.URL,
+			Description: articleDescription(arti),
+			PubDate:     arti.Date.UTC().Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: true, Value: siteURL + arti.URL},
+			Categories:  arti.Tags,
+		})
+	}
+	// derived from the content rather than the clock so unchanged content builds the same feed
+	if !lastBuild.IsZero() {
+		feed.Channel.LastBuildDate = lastBuild.UTC().Format(time.RFC1123Z)
+	}
+
+	return marshalXML(feed)
+}
+
+// articles must be sorted newest first
+func buildSiteMap(articles []Article) ([]byte, error) {
+	urls := []sitemapURL{
+		{Loc: siteURL + "/"},
+		{Loc: siteURL + "/feed/"},
+	}
+
+	seriesLastMod := make(map[string]time.Time)
+	for i := range articles {
+		arti := &articles[i]
+		urls = append(urls, sitemapURL{
+			Loc:     siteURL + arti.URL,
+			LastMod: arti.Date.UTC().Format(time.DateOnly),
+		})
+		if arti.Series != "" && arti.Date.After(seriesLastMod[arti.Series]) {
+			seriesLastMod[arti.Series] = arti.Date
+		}
+	}
+
+	seriesSlugs := make([]string, 0, len(seriesLastMod))
+	for slug := range seriesLastMod {
+		seriesSlugs = append(seriesSlugs, slug)
+	}
+	sort.Strings(seriesSlugs)
+	for _, slug := range seriesSlugs {
+		urls = append(urls, sitemapURL{
+			Loc:     siteURL + "/series/" + slug,
+			LastMod: seriesLastMod[slug].UTC().Format(time.DateOnly),
+		})
+	}
+
+	return marshalXML(sitemapURLSet{URLs: urls})
+}
+
+// articles must be sorted newest first
+func buildAtomFeed(articles []Article) ([]byte, error) {
+	feed := atomFeed{
+		Title: siteTitle,
+		ID:    siteURL + "/",
+		Links: []atomLink{
+			{Href: siteURL + "/feed/atom.xml", Rel: "self", Type: "application/atom+xml"},
+			{Href: siteURL + "/", Rel: "alternate", Type: "text/html"},
+		},
+		Author:  atomPerson{Name: siteAuthor},
+		Entries: make([]atomEntry, 0, len(articles)),
+	}
+
+	var feedUpdated time.Time
+	for i := range articles {
+		arti := &articles[i]
+		updated := articleUpdated(arti)
+		if updated.After(feedUpdated) {
+			feedUpdated = updated
+		}
+
+		entry := atomEntry{
+			Title:     arti.Title,
+			ID:        siteURL + arti.URL,
+			Published: arti.Date.UTC().Format(time.RFC3339),
+			Updated:   updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: siteURL + arti.URL, Rel: "alternate", Type: "text/html"}},
+			Content:   atomText{Type: "html", Body: string(arti.Body)},
+		}
+		if arti.Author != "" {
+			entry.Author = &atomPerson{Name: arti.Author}
+		}
+		if arti.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: arti.Summary}
+		}
+		for _, tag := range arti.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if !feedUpdated.IsZero() {
+		feed.Updated = feedUpdated.UTC().Format(time.RFC3339)
+	}
+
+	return marshalXML(feed)
+}
+
+// articles must be sorted newest first
+func buildJSONFeed(articles []Article) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       siteTitle,
+		HomePageURL: siteURL + "/",
+		FeedURL:     siteURL + "/feed/feed.json",
+		Description: siteDescription,
+		Authors:     []jsonFeedAuthor{{Name: siteAuthor}},
+		Items:       make([]jsonFeedItem, 0, len(articles)),
+	}
+
+	for i := range articles {
+		arti := &articles[i]
+		item := jsonFeedItem{
+			ID:            siteURL + arti.URL,
+			URL:           siteURL + arti.URL,
+			Title:         arti.Title,
+			ContentHTML:   string(arti.Body),
+			Summary:       arti.Summary,
+			DatePublished: arti.Date.UTC().Format(time.RFC3339),
+			Tags:          arti.Tags,
+		}
+		if !arti.Updated.IsZero() {
+			item.DateModified = articleUpdated(arti).UTC().Format(time.RFC3339)
+		}
+		if arti.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: arti.Author}}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return json.MarshalIndent(feed, "", "  ")
+}
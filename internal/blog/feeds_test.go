@@ -0,0 +1,120 @@
+package blog
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var feedArticles = []Article{
+	{
+		Title:   "Tips & <Tricks>",
+		Slug:    "tips",
+		URL:     "/article/tips",
+		Body:    []byte("<p>hello</p>"),
+		Date:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Updated: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Summary: "short",
+		Tags:    []string{"go"},
+		Author:  "Guest Author",
+	},
+	{
+		Title: "Older",
+		Slug:  "older",
+		URL:   "/article/older",
+		Body:  []byte("<p>old</p>"),
+		Date:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestBuildAtomFeed(t *testing.T) {
+	out, err := buildAtomFeed(feedArticles)
+	require.NoError(t, err)
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(out, &feed))
+	require.Equal(t, "2024-03-01T00:00:00Z", feed.Updated)
+	require.Len(t, feed.Entries, 2)
+
+	entry := feed.Entries[0]
+	require.Equal(t, "Tips & <Tricks>", entry.Title)
+	require.Equal(t, "https://jake-henning.com/article/tips", entry.ID)
+	require.Equal(t, "<p>hello</p>", entry.Content.Body)
+	require.Equal(t, "Guest Author", entry.Author.Name)
+	require.Equal(t, "short", entry.Summary.Body)
+
+	empty, err := buildAtomFeed(nil)
+	require.NoError(t, err)
+	require.NotContains(t, string(empty), "<updated>", "an empty feed has no date to give")
+	require.Equal(t, []atomCategory{{Term: "go"}}, entry.Categories)
+	require.Nil(t, feed.Entries[1].Author)
+}
+
+func TestBuildJSONFeed(t *testing.T) {
+	out, err := buildJSONFeed(feedArticles)
+	require.NoError(t, err)
+
+	var feed jsonFeed
+	require.NoError(t, json.Unmarshal(out, &feed))
+	require.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	require.Len(t, feed.Items, 2)
+	require.Equal(t, "2024-03-01T00:00:00Z", feed.Items[0].DateModified)
+	require.Equal(t, []string{"go"}, feed.Items[0].Tags)
+	require.Empty(t, feed.Items[1].DateModified)
+}
+
+func TestBuildRSSFeed(t *testing.T) {
+	out, err := buildRSSFeed(siteTitle, siteURL+"/feed/", feedArticles)
+	require.NoError(t, err)
+
+	feed := string(out)
+	require.Contains(t, feed, `<atom:link href="https://jake-henning.com/feed/" rel="self" type="application/rss+xml"></atom:link>`)
+	require.Contains(t, feed, `<title>Tips &amp; &lt;Tricks&gt;</title>`)
+	require.Contains(t, feed, `<link>https://jake-henning.com/article/tips</link>`)
+	require.Contains(t, feed, `<description>short</description>`)
+	require.Contains(t, feed, `<description>old</description>`)
+	require.Contains(t, feed, `<category>go</category>`)
+	require.Contains(t, feed, `<pubDate>Thu, 01 Feb 2024 00:00:00 +0000</pubDate>`)
+	require.Contains(t, feed, `<lastBuildDate>Fri, 01 Mar 2024 00:00:00 +0000</lastBuildDate>`)
+	require.Contains(t, feed, `<guid isPermaLink="true">https://jake-henning.com/article/tips</guid>`)
+
+	var parsed rssFeed
+	require.NoError(t, xml.Unmarshal(out, &parsed))
+	require.Len(t, parsed.Channel.Items, 2)
+	require.Equal(t, "Tips & <Tricks>", parsed.Channel.Items[0].Title)
+}
+
+func TestBuildSiteMap(t *testing.T) {
+	out, err := buildSiteMap(feedArticles)
+	require.NoError(t, err)
+
+	var parsed sitemapURLSet
+	require.NoError(t, xml.Unmarshal(out, &parsed))
+	require.Equal(t, []sitemapURL{
+		{Loc: "https://jake-henning.com/"},
+		{Loc: "https://jake-henning.com/feed/"},
+		{Loc: "https://jake-henning.com/article/tips", LastMod: "2024-02-01"},
+		{Loc: "https://jake-henning.com/article/older", LastMod: "2023-01-01"},
+	}, parsed.URLs)
+}
+
+func TestFeedItemsCap(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FeedItems = 1
+
+	rendered := map[string]Article{}
+	for _, arti := range feedArticles {
+		rendered[arti.Slug] = arti
+	}
+	idx := buildContentIndex(context.Background(), cfg, nil, rendered, nil, time.Now())
+
+	var parsed rssFeed
+	require.NoError(t, xml.Unmarshal(idx.rssFeed, &parsed))
+	require.Len(t, parsed.Channel.Items, 1)
+	require.Equal(t, "https://jake-henning.com/article/tips", parsed.Channel.Items[0].Link, "newest article first")
+	require.Contains(t, string(idx.siteMap), "/article/older", "sitemap is not capped")
+}
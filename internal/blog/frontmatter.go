@@ -0,0 +1,59 @@
+package blog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is the optional yaml block at the top of an article
+// delimited by --- lines. every field is optional
+type FrontMatter struct {
+	Title     string    `yaml:"title"`
+	Date      time.Time `yaml:"date"`
+	Updated   time.Time `yaml:"updated"`
+	Summary   string    `yaml:"summary"`
+	Tags      []string  `yaml:"tags"`
+	Slug      string    `yaml:"slug"`
+	Draft     bool      `yaml:"draft"`
+	Unlisted  bool      `yaml:"unlisted"`
+	Publish   time.Time `yaml:"publish"` // scheduled publish timestamp
+	Part      int       `yaml:"part"`    // position within a series directory
+	Author    string    `yaml:"author"`
+	Canonical string    `yaml:"canonical"`
+}
+
+var frontMatterDelim = []byte("---")
+
+var errMalformedFrontMatter = errors.New("malformed front matter")
+
+// parseFrontMatter splits an optional front matter block from the markdown body.
+// content without front matter is returned untouched with a zero FrontMatter
+func parseFrontMatter(content []byte) (FrontMatter, []byte, error) {
+	var fm FrontMatter
+
+	trimmed := bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // utf-8 bom
+	firstLine, rest, found := bytes.Cut(trimmed, []byte("\n"))
+	if !found || !bytes.Equal(bytes.TrimSpace(firstLine), frontMatterDelim) {
+		return fm, content, nil
+	}
+
+	var block []byte
+	for len(rest) > 0 {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte("\n"))
+		if bytes.Equal(bytes.TrimSpace(line), frontMatterDelim) {
+			if err := yaml.Unmarshal(block, &fm); err != nil {
+				return FrontMatter{}, nil, fmt.Errorf("%w: %w", errMalformedFrontMatter, err)
+			}
+			return fm, rest, nil
+		}
+		block = append(block, line...)
+		block = append(block, '\n')
+	}
+
+	return FrontMatter{}, nil, fmt.Errorf("%w: missing closing delimiter", errMalformedFrontMatter)
+}
@@ -0,0 +1,74 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    FrontMatter
+		expectedMD  string
+		expectError bool
+	}{
+		{
+			name:       "no front matter",
+			content:    "# Title\nbody",
+			expectedMD: "# Title\nbody",
+		},
+		{
+			name: "full front matter",
+			content: `---
+title: Hello & Goodbye
+date: 2024-03-01
+updated: 2024-03-05T10:00:00Z
+summary: short summary
+tags: [go, sre]
+slug: hello
+draft: true
+author: Jacob Henning
+canonical: https://example.com/hello
+---
+# Body`,
+			expected: FrontMatter{
+				Title:     "Hello & Goodbye",
+				Date:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				Updated:   time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC),
+				Summary:   "short summary",
+				Tags:      []string{"go", "sre"},
+				Slug:      "hello",
+				Draft:     true,
+				Author:    "Jacob Henning",
+				Canonical: "https://example.com/hello",
+			},
+			expectedMD: "# Body",
+		},
+		{
+			name:        "missing closing delimiter",
+			content:     "---\ntitle: oops\n# Body",
+			expectError: true,
+		},
+		{
+			name:        "invalid yaml",
+			content:     "---\ntags: [unterminated\n---\nbody",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body, err := parseFrontMatter([]byte(tt.content))
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, fm)
+			require.Equal(t, tt.expectedMD, string(body))
+		})
+	}
+}
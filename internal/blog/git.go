@@ -1,73 +1,263 @@
 package blog
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-func getFileLastModified(cfg *Config, filePath string) (time.Time, error) {
+// fileHistory is when a file was first and last committed
+type fileHistory struct {
+	Created time.Time
+	Updated time.Time
+}
+
+// scanFileHistory walks the commit log from HEAD once, diffing each commit's tree
+// against its first parent to find the first and last commit for every path.
+// a merge only counts for files that match none of its other parents, so files
+// from a merged branch are dated by the commits on that branch. paths are slash
+// separated and relative to the repo root. renames are followed so moving a file
+// keeps its created date. the walk stops once every path has been traced back to
+// the commit that added it
+func scanFileHistory(cfg *Config, paths []string) (map[string]fileHistory, error) {
+	history := make(map[string]fileHistory, len(paths))
 	if cfg.LocalOnly {
-		return time.Now(), nil
+		// without commits the file's last write stands in for both
+		for _, p := range paths {
+			info, err := os.Stat(filepath.Join(cfg.ContentDir, filepath.FromSlash(p)))
+			if err != nil {
+				continue // removed since it was found, reported like any file without history
+			}
+			history[p] = fileHistory{Created: info.ModTime(), Updated: info.ModTime()}
+		}
+		return history, nil
+	}
+	if len(paths) == 0 {
+		return history, nil
 	}
 
 	repo, err := git.PlainOpen(cfg.ContentDir)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to open git repo: %w", err)
+		return nil, fmt.Errorf("failed to open git repo: %w", err)
 	}
 
 	ref, err := repo.Head()
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to get repo HEAD: %w", err)
+		return nil, fmt.Errorf("failed to get repo HEAD: %w", err)
 	}
 
-	// Get commit history
-	cIter, err := repo.Log(&git.LogOptions{From: ref.Hash()})
+	cIter, err := repo.Log(&git.LogOptions{From: ref.Hash(), Order: git.LogOrderCommitterTime})
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to get commit history: %w", err)
+		return nil, fmt.Errorf("failed to get commit history: %w", err)
+	}
+	defer cIter.Close()
+
+	// historical path -> path at HEAD, grows as renames are found
+	tracked := make(map[string]string, len(paths))
+	for _, p := range paths {
+		tracked[p] = p
 	}
+	pending := len(paths)
 
-	var latestCommitDate time.Time
+	commits := 0
 	err = cIter.ForEach(func(c *object.Commit) error {
 		if c == nil {
 			return fmt.Errorf("encountered nil commit")
 		}
+		commits++
 
-		// Get the files changed in this commit
-		files, err := c.Files()
+		tree, err := c.Tree()
 		if err != nil {
-			return fmt.Errorf("failed to get files for commit %s: %w", c.Hash, err)
+			return fmt.Errorf("failed to get tree for commit %s: %w", c.Hash, err)
+		}
+		var parentTree *object.Tree
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return fmt.Errorf("failed to get parent of commit %s: %w", c.Hash, err)
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return fmt.Errorf("failed to get tree for commit %s: %w", parent.Hash, err)
+			}
 		}
 
-		// Check if our target file was changed
-		if err := files.ForEach(func(f *object.File) error {
-			if f == nil {
-				return fmt.Errorf("encountered nil file")
+		// the other parents of a merge
+		var mergedTrees []*object.Tree
+		for i := 1; i < c.NumParents(); i++ {
+			parent, err := c.Parent(i)
+			if err != nil {
+				return fmt.Errorf("failed to get parent of commit %s: %w", c.Hash, err)
 			}
-			if f.Name == filePath {
-				latestCommitDate = c.Author.When
+			mergedTree, err := parent.Tree()
+			if err != nil {
+				return fmt.Errorf("failed to get tree for commit %s: %w", parent.Hash, err)
 			}
-			return nil
-		}); err != nil {
-			return fmt.Errorf("failed to iterate files in commit %s: %w", c.Hash, err)
+			mergedTrees = append(mergedTrees, mergedTree)
 		}
 
+		changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+		if err != nil {
+			return fmt.Errorf("failed to diff commit %s: %w", c.Hash, err)
+		}
+
+		for _, change := range changes {
+			if change.To.Name == "" { // deletion
+				continue
+			}
+			current, found := tracked[change.To.Name]
+			if !found {
+				continue
+			}
+			added, merged := change.From.Name == "", false
+			for _, mt := range mergedTrees {
+				entry, err := mt.FindEntry(change.To.Name)
+				if err != nil {
+					continue
+				}
+				// unchanged from that parent, whose commits have the dates. otherwise
+				// it was edited while merging and the other parent still added it
+				merged = merged || entry.Hash == change.To.TreeEntry.Hash
+				added = false
+			}
+			if merged {
+				continue
+			}
+
+			h := history[current]
+			if h.Updated.IsZero() {
+				h.Updated = c.Author.When
+			}
+			h.Created = c.Author.When
+			history[current] = h
+
+			switch {
+			case added: // added here so this is the oldest commit that matters
+				delete(tracked, change.To.Name)
+				pending--
+			case change.From.Name != "" && change.From.Name != change.To.Name: // renamed, keep following the old path
+				delete(tracked, change.To.Name)
+				tracked[change.From.Name] = current
+			}
+		}
+
+		if pending == 0 {
+			return storer.ErrStop
+		}
 		return nil
 	})
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to iterate commits: %w", err)
+		return nil, fmt.Errorf("failed to iterate commits: %w", err)
 	}
 
-	if latestCommitDate.IsZero() {
-		return time.Time{}, fmt.Errorf("file %s not found in git history", filePath)
+	mdLogger.Debug().Int("commits", commits).Int("files", len(paths)).Msg("git history scanned")
+	return history, nil
+}
+
+// keepCreated pins LocalOnly created dates to the earliest mtime each file was
+// seen with, so saving a post in dev mode moves its updated date but not its
+// place in the lists. a restart starts over from the current mtimes
+func (bm *BlogManager) keepCreated(history map[string]fileHistory) {
+	if bm.firstSeen == nil {
+		bm.firstSeen = make(map[string]time.Time, len(history))
+	}
+	for p, h := range history {
+		if created, found := bm.firstSeen[p]; found && created.Before(h.Created) {
+			h.Created = created
+			history[p] = h
+			continue
+		}
+		bm.firstSeen[p] = h.Created
 	}
+}
 
-	return latestCommitDate, nil
+// headHash is the commit checked out in the content repo, zero when LocalOnly
+func headHash(cfg *Config) (plumbing.Hash, error) {
+	if cfg.LocalOnly {
+		return plumbing.ZeroHash, nil
+	}
+
+	repo, err := git.PlainOpen(cfg.ContentDir)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to open git repo: %w", err)
+	}
+	ref, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get repo HEAD: %w", err)
+	}
+	return ref.Hash(), nil
+}
+
+// changedFiles diffs the trees of two commits and returns every path that was
+// added or modified between them. deletions are left out since the caller only
+// renders files that still exist
+func changedFiles(cfg *Config, from, to plumbing.Hash) (map[string]bool, error) {
+	repo, err := git.PlainOpen(cfg.ContentDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repo: %w", err)
+	}
+
+	trees := make([]*object.Tree, 0, 2)
+	for _, hash := range []plumbing.Hash{from, to} {
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tree for commit %s: %w", hash, err)
+		}
+		trees = append(trees, tree)
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", from, to, err)
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		if change.To.Name != "" {
+			changed[change.To.Name] = true
+		}
+	}
+	return changed, nil
+}
+
+func repoAuth(cfg *Config) (*ssh.PublicKeys, error) {
+	return ssh.NewPublicKeysFromFile("git", cfg.KeyPrivPath, cfg.RepoPass)
+}
+
+// remoteHeadHash asks the remote for the tip of branch without fetching any
+// objects, the same as git ls-remote
+func remoteHeadHash(ctx context.Context, url, branch string, auth transport.AuthMethod) (plumbing.Hash, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	for _, ref := range refs {
+		if ref.Name() == name {
+			return ref.Hash(), nil
+		}
+	}
+	return plumbing.ZeroHash, fmt.Errorf("remote has no branch %s", branch)
 }
 
 func FetchMarkdownRepo(cfg *Config) error {
@@ -76,7 +266,7 @@ func FetchMarkdownRepo(cfg *Config) error {
 		return nil
 	}
 
-	sshAuth, err := ssh.NewPublicKeysFromFile("git", cfg.KeyPrivPath, cfg.RepoPass)
+	sshAuth, err := repoAuth(cfg)
 	if err != nil {
 		mdLogger.Error().Msgf("error loading SSH keys: %v", err)
 
@@ -85,7 +275,8 @@ func FetchMarkdownRepo(cfg *Config) error {
 
 	_, err = git.PlainClone(cfg.ContentDir, false, &git.CloneOptions{
 		URL:           cfg.RepoURL,
-		ReferenceName: plumbing.ReferenceName("refs/heads/main"),
+		ReferenceName: plumbing.NewBranchReferenceName(cfg.ContentBranch),
+		SingleBranch:  true,
 		Auth:          sshAuth,
 	})
 	if err != nil {
@@ -103,8 +294,10 @@ func FetchMarkdownRepo(cfg *Config) error {
 				return err
 			}
 			err = worktree.Pull(&git.PullOptions{
-				RemoteName: "origin",
-				Auth:       sshAuth,
+				RemoteName:    "origin",
+				ReferenceName: plumbing.NewBranchReferenceName(cfg.ContentBranch),
+				SingleBranch:  true,
+				Auth:          sshAuth,
 			})
 			if err != nil && err != git.NoErrAlreadyUpToDate {
 				log.Printf("failed to pull repo: %v", err)
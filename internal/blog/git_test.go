@@ -0,0 +1,172 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func day(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+// testRepo returns a fresh repo and a helper that writes, removes and commits files
+func testRepo(t *testing.T) (string, func(time.Time, map[string]string, ...string) plumbing.Hash) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	return dir, func(when time.Time, files map[string]string, removed ...string) plumbing.Hash {
+		for name, content := range files {
+			p := filepath.Join(dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+			_, err := wt.Add(name)
+			require.NoError(t, err)
+		}
+		for _, name := range removed {
+			_, err := wt.Remove(name)
+			require.NoError(t, err)
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: when}
+		hash, err := wt.Commit("commit", &git.CommitOptions{Author: sig, Committer: sig})
+		require.NoError(t, err)
+		return hash
+	}
+}
+
+func TestScanFileHistory(t *testing.T) {
+	dir, commit := testRepo(t)
+
+	moved := "a long enough article body that rename detection can match it across paths\n"
+	commit(day(1), map[string]string{"a.md": "first", "old.md": moved})
+	commit(day(2), map[string]string{"b.md": "second"})
+	commit(day(3), map[string]string{"a.md": "first edited"})
+	commit(day(4), map[string]string{"series/new.md": moved}, "old.md")
+	commit(day(5), map[string]string{"unrelated.md": "noise"})
+
+	cfg := DefaultConfig()
+	cfg.ContentDir = dir
+	history, err := scanFileHistory(cfg, []string{"a.md", "b.md", "series/new.md"})
+	require.NoError(t, err)
+
+	require.True(t, history["a.md"].Created.Equal(day(1)))
+	require.True(t, history["a.md"].Updated.Equal(day(3)))
+	require.True(t, history["b.md"].Created.Equal(day(2)))
+	require.True(t, history["b.md"].Updated.Equal(day(2)))
+	require.True(t, history["series/new.md"].Created.Equal(day(1)), "renames keep the created date")
+	require.True(t, history["series/new.md"].Updated.Equal(day(4)))
+}
+
+func TestScanFileHistoryMerge(t *testing.T) {
+	dir, commit := testRepo(t)
+	base := commit(day(1), map[string]string{"a.md": "a"})
+	side := commit(day(2), map[string]string{"side.md": "side", "edited.md": "side version"})
+
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset}))
+	tip := commit(day(3), map[string]string{"b.md": "b"})
+
+	// merge side into main, editing edited.md while resolving
+	for name, content := range map[string]string{"side.md": "side", "edited.md": "merged version"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: day(4)}
+	_, err = wt.Commit("merge", &git.CommitOptions{Author: sig, Committer: sig, Parents: []plumbing.Hash{tip, side}})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.ContentDir = dir
+	history, err := scanFileHistory(cfg, []string{"a.md", "b.md", "side.md", "edited.md"})
+	require.NoError(t, err)
+
+	require.True(t, history["side.md"].Created.Equal(day(2)), "dated by the branch commit, not the merge")
+	require.True(t, history["side.md"].Updated.Equal(day(2)))
+	require.True(t, history["edited.md"].Created.Equal(day(2)))
+	require.True(t, history["edited.md"].Updated.Equal(day(4)), "edits made while merging count")
+	require.True(t, history["b.md"].Created.Equal(day(3)))
+	require.True(t, history["a.md"].Created.Equal(day(1)))
+}
+
+func TestScanFileHistoryLocalOnly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "series"), 0o755))
+	for name, when := range map[string]time.Time{"a.md": day(1), "series/b.md": day(2)} {
+		p := filepath.Join(cfg.ContentDir, filepath.FromSlash(name))
+		require.NoError(t, os.WriteFile(p, []byte("# "+name), 0o644))
+		require.NoError(t, os.Chtimes(p, when, when))
+	}
+
+	history, err := scanFileHistory(cfg, []string{"a.md", "series/b.md", "gone.md"})
+	require.NoError(t, err)
+	require.True(t, history["a.md"].Created.Equal(day(1)), "dated by modification time")
+	require.True(t, history["a.md"].Updated.Equal(day(1)))
+	require.True(t, history["series/b.md"].Updated.Equal(day(2)))
+	require.NotContains(t, history, "gone.md")
+}
+
+func TestFilesToRender(t *testing.T) {
+	dir, commit := testRepo(t)
+	first := commit(day(1), map[string]string{"a.md": "a", "b.md": "b", "broken.md": "x"})
+	second := commit(day(2), map[string]string{"a.md": "a edited", "c.md": "c"})
+
+	cfg := DefaultConfig()
+	cfg.ContentDir = dir
+	bm := NewBlogManager(cfg)
+	paths := []string{"a.md", "b.md", "broken.md", "c.md"}
+
+	render, incremental := bm.filesToRender(second, paths)
+	require.False(t, incremental, "first update renders everything")
+	require.Equal(t, map[string]bool{"a.md": true, "b.md": true, "broken.md": true, "c.md": true}, render)
+
+	// broken.md failed to render last time so it is not cached
+	bm.lastHead = first
+	bm.fileCache = map[string]Article{"a.md": {}, "b.md": {}}
+
+	render, incremental = bm.filesToRender(second, paths)
+	require.True(t, incremental)
+	require.Equal(t, map[string]bool{"a.md": true, "b.md": false, "broken.md": true, "c.md": true}, render)
+
+	bm.lastHead = second
+	bm.fileCache = map[string]Article{"a.md": {}, "b.md": {}, "broken.md": {}, "c.md": {}}
+	render, _ = bm.filesToRender(second, paths)
+	require.Equal(t, map[string]bool{"a.md": false, "b.md": false, "broken.md": false, "c.md": false}, render)
+}
+
+func TestLocalOnlyCreatedKept(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	write := func(name string, when time.Time) {
+		p := filepath.Join(cfg.ContentDir, name)
+		require.NoError(t, os.WriteFile(p, []byte("# "+name), 0o644))
+		require.NoError(t, os.Chtimes(p, when, when))
+	}
+	write("old.md", day(1))
+	write("new.md", day(2))
+
+	bm := NewBlogManager(cfg)
+	require.NoError(t, bm.updateContent())
+
+	// saving a post in dev mode does not move it above newer ones
+	write("old.md", day(5))
+	require.NoError(t, bm.updateContent())
+	old, _ := bm.GetArticle("old")
+	require.True(t, old.Date.Equal(day(1)))
+	require.True(t, old.Updated.Equal(day(5)))
+	require.Less(t, strings.Index(string(bm.HTMLList), "/article/new"), strings.Index(string(bm.HTMLList), "/article/old"))
+}
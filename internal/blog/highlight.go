@@ -0,0 +1,168 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	chtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// fenced code blocks are highlighted when the page is rendered since the csp keeps
+// out third party scripts. spans only get classes, colours come from /highlight.css
+// so the inline style free csp holds
+
+const highlightCSSPath = "/highlight.css"
+
+// codeInfo is the info string of a fenced code block, the language followed by
+// optional attributes, e.g. go {linenos=true linenostart=10 hl_lines="2-4 7"}
+type codeInfo struct {
+	lang        string
+	lineNumbers bool
+	lineStart   int
+	highlight   [][2]int // counted from the first line of the block
+}
+
+func parseCodeInfo(info string) codeInfo {
+	ci := codeInfo{lineStart: 1}
+	info = strings.TrimSpace(info)
+	lang, attrs, _ := strings.Cut(info, " ")
+	if strings.HasPrefix(lang, "{") {
+		lang, attrs = "", info
+	}
+	ci.lang = strings.ToLower(lang)
+
+	attrs = strings.TrimSpace(attrs)
+	attrs = strings.TrimSuffix(strings.TrimPrefix(attrs, "{"), "}")
+	for _, attr := range splitCodeAttrs(attrs) {
+		key, value, _ := strings.Cut(attr, "=")
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "linenos":
+			ci.lineNumbers = value == "" || value == "true" || value == "table" || value == "inline"
+		case "linenostart":
+			if start, err := strconv.Atoi(value); err == nil && start > 0 {
+				ci.lineStart = start
+			} else {
+				mdLogger.Warn().Msgf("ignoring code block linenostart %q", value)
+			}
+		case "hl_lines":
+			ci.highlight = parseLineRanges(value)
+		default:
+			mdLogger.Warn().Msgf("ignoring unknown code block attribute %q", attr)
+		}
+	}
+	return ci
+}
+
+// splitCodeAttrs splits on spaces and commas outside of quotes
+func splitCodeAttrs(attrs string) []string {
+	var out []string
+	var cur strings.Builder
+	quote := rune(0)
+	for _, r := range attrs {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) || r == ',':
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+// parseLineRanges reads "2-4 7" style line lists, bad entries are skipped
+func parseLineRanges(value string) [][2]int {
+	var ranges [][2]int
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(from)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(to)
+		}
+		if err != nil || start < 1 || end < start {
+			mdLogger.Warn().Msgf("ignoring code block hl_lines entry %q", field)
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// codeLexer is nil for blocks without a language or with one chroma does not know,
+// those are rendered as plain code blocks
+func codeLexer(lang string) chroma.Lexer {
+	if lang == "" {
+		return nil
+	}
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return nil
+	}
+	return chroma.Coalesce(lexer)
+}
+
+func highlightCode(lexer chroma.Lexer, ci codeInfo, code []byte) ([]byte, error) {
+	tokens, err := lexer.Tokenise(nil, string(code))
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenise %s code block: %w", ci.lang, err)
+	}
+
+	opts := []chtml.Option{chtml.WithClasses(true)}
+	if ci.lineNumbers {
+		opts = append(opts, chtml.WithLineNumbers(true), chtml.BaseLineNumber(ci.lineStart))
+	}
+	if len(ci.highlight) > 0 {
+		// chroma compares against the displayed line numbers
+		ranges := make([][2]int, len(ci.highlight))
+		for i, r := range ci.highlight {
+			ranges[i] = [2]int{r[0] + ci.lineStart - 1, r[1] + ci.lineStart - 1}
+		}
+		opts = append(opts, chtml.HighlightLines(ranges))
+	}
+
+	var buf bytes.Buffer
+	// the style is only used for inline styles, class output is the same for any style
+	if err := chtml.New(opts...).Format(&buf, styles.Fallback, tokens); err != nil {
+		return nil, fmt.Errorf("failed to highlight %s code block: %w", ci.lang, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func validHighlightStyle(name string) bool {
+	_, ok := styles.Registry[strings.ToLower(name)]
+	return ok
+}
+
+// HighlightCSS is the stylesheet for the highlight classes in the named chroma style
+func HighlightCSS(style string) ([]byte, error) {
+	s, ok := styles.Registry[strings.ToLower(style)]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", style)
+	}
+	var buf bytes.Buffer
+	if err := chtml.New(chtml.WithClasses(true)).WriteCSS(&buf, s); err != nil {
+		return nil, fmt.Errorf("failed to write highlight css: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,113 @@
+package blog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCodeInfo(t *testing.T) {
+	tests := []struct {
+		info string
+		want codeInfo
+	}{
+		{"", codeInfo{lineStart: 1}},
+		{"Go", codeInfo{lang: "go", lineStart: 1}},
+		{"go {linenos=true}", codeInfo{lang: "go", lineNumbers: true, lineStart: 1}},
+		{`go {linenos=true linenostart=10 hl_lines="2-4 7"}`, codeInfo{
+			lang: "go", lineNumbers: true, lineStart: 10, highlight: [][2]int{{2, 4}, {7, 7}},
+		}},
+		{`python {hl_lines="1,3-3",linenos=false}`, codeInfo{
+			lang: "python", lineStart: 1, highlight: [][2]int{{1, 1}, {3, 3}},
+		}},
+		{`sh {linenostart=zero hl_lines="4-2 x 5" bogus}`, codeInfo{
+			lang: "sh", lineStart: 1, highlight: [][2]int{{5, 5}},
+		}},
+		{"{linenos=true}", codeInfo{lineNumbers: true, lineStart: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.info, func(t *testing.T) {
+			require.Equal(t, tt.want, parseCodeInfo(tt.info))
+		})
+	}
+}
+
+func TestHighlightCodeBlocks(t *testing.T) {
+	md := []byte("```go {linenos=true linenostart=10 hl_lines=\"2\"}\n" +
+		"package main\n" +
+		"func main() {}\n" +
+		"```\n\n" +
+		"```nosuchlang\n<b>\n```\n\n" +
+		"```\nplain\n```\n")
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			html := string(out)
+
+			require.Contains(t, html, `<pre class="chroma">`)
+			require.Contains(t, html, `<span class="kn">package</span>`)
+			require.Contains(t, html, `<span class="ln">10</span>`)
+			require.Contains(t, html, `<span class="line hl"><span class="ln">11</span>`)
+			require.NotContains(t, html, "style=", "the csp forbids inline styles")
+
+			// unknown and missing languages are plain code blocks
+			require.Contains(t, html, `<code class="language-nosuchlang">&lt;b&gt;`)
+			require.Contains(t, html, "<pre><code>plain\n</code></pre>")
+		})
+	}
+}
+
+// brokenLexer fails to tokenise anything
+type brokenLexer struct{ chroma.Lexer }
+
+func (brokenLexer) Config() *chroma.Config {
+	return &chroma.Config{Name: "broken", Aliases: []string{"broken"}}
+}
+
+func (brokenLexer) Tokenise(*chroma.TokeniseOptions, string) (chroma.Iterator, error) {
+	return nil, errors.New("broken lexer")
+}
+
+func TestHighlightErrorFallback(t *testing.T) {
+	if lexers.Get("broken") == nil {
+		lexers.Register(brokenLexer{lexers.Fallback})
+	}
+	md := []byte("# Post\n\n```broken\n<b>\n```\n\nafter")
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := NewRenderer(cfg).Render(md)
+			require.NoError(t, err, "a block that fails to highlight does not fail the article")
+			require.Contains(t, string(out), `<pre><code class="language-broken">&lt;b&gt;`+"\n</code></pre>")
+			require.Contains(t, string(out), "<p>after</p>")
+		})
+	}
+}
+
+func TestHighlightCSS(t *testing.T) {
+	css, err := HighlightCSS("GitHub")
+	require.NoError(t, err)
+	require.Contains(t, string(css), ".chroma .kn")
+
+	_, err = HighlightCSS("nosuchstyle")
+	require.Error(t, err)
+
+	cfg := DefaultConfig()
+	cfg.RepoURL = "git@example.com:blog.git"
+	cfg.Env = "test"
+	require.NoError(t, cfg.Validate())
+	cfg.HighlightStyle = "nosuchstyle"
+	require.Error(t, cfg.Validate())
+}
@@ -0,0 +1,430 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/image/draw"
+)
+
+// png and jpeg images in ContentDir/images are re-encoded at content update into
+// responsive width variants and a thumbnail. go's encoders write no metadata so
+// exif and gps data never leave the content repo. the exif orientation of a jpeg
+// is applied to the pixels first so photos taken on their side stay upright. files
+// are named by the hash of the source so they never need invalidating and old
+// snapshots keep working
+
+const (
+	imageURLPrefix = "/article/images/"
+	thumbnailWidth = 320
+	jpegQuality    = 85
+
+	// hashed in with the source so files made by an older pipeline are not reused
+	imagePipelineVersion = "2"
+)
+
+// widths of the responsive variants, only those narrower than the source are made
+var imageWidths = []int{480, 960, 1600}
+
+// imageVariant is one file in the image directory
+type imageVariant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// processedImage is what the pipeline made from one source image
+type processedImage struct {
+	Hash      string         // of the source file and imagePipelineVersion
+	Original  imageVariant   // full size without metadata
+	Variants  []imageVariant // narrower than the original, ascending
+	Thumbnail imageVariant
+}
+
+func (p processedImage) files() []imageVariant {
+	files := []imageVariant{p.Original, p.Thumbnail}
+	return append(files, p.Variants...)
+}
+
+// imageSet is every processed image by its path under ContentDir/images
+type imageSet map[string]processedImage
+
+// imageDir is where processed images are written, next to the checkout by default
+func imageDir(cfg *Config) string {
+	if cfg.ImageDir != "" {
+		return cfg.ImageDir
+	}
+	return filepath.Clean(cfg.ContentDir) + ".images"
+}
+
+// imagesChanged reports whether any article could render differently with next
+func imagesChanged(prev, next imageSet) bool {
+	if len(prev) != len(next) {
+		return true
+	}
+	for name, img := range next {
+		if old, found := prev[name]; !found || old.Hash != img.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+func scaledSize(width, height, toWidth int) (int, int) {
+	return toWidth, max(1, (height*toWidth+width/2)/width)
+}
+
+// planImage names the files for a source image without decoding it
+func planImage(hash, format string, width, height int) processedImage {
+	ext := ".png"
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	p := processedImage{
+		Hash:     hash,
+		Original: imageVariant{Name: hash + ext, Width: width, Height: height},
+	}
+	for _, w := range imageWidths {
+		if w >= width {
+			break
+		}
+		vw, vh := scaledSize(width, height, w)
+		p.Variants = append(p.Variants, imageVariant{Name: fmt.Sprintf("%s-%d%s", hash, w, ext), Width: vw, Height: vh})
+	}
+	tw, th := scaledSize(width, height, min(thumbnailWidth, width))
+	p.Thumbnail = imageVariant{Name: hash + "-thumb" + ext, Width: tw, Height: th}
+	return p
+}
+
+var errUnsupportedImage = errors.New("not a png or jpeg image")
+
+// processImage writes whatever files for src are missing from dir. images that were
+// processed before are only hashed and have their header read
+func processImage(dir string, src []byte) (processedImage, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return processedImage{}, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if format != "png" && format != "jpeg" {
+		return processedImage{}, errUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		// the header is all DecodeConfig checks and sizes are divided by later
+		return processedImage{}, fmt.Errorf("image is %dx%d: %w", cfg.Width, cfg.Height, errUnsupportedImage)
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(src)
+	}
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 { // turned a quarter
+		width, height = height, width
+	}
+
+	sum := sha256.Sum256(append([]byte(imagePipelineVersion), src...))
+	p := planImage(hex.EncodeToString(sum[:])[:16], format, width, height)
+
+	var missing []imageVariant
+	for _, v := range p.files() {
+		if _, err := os.Stat(filepath.Join(dir, v.Name)); err != nil {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) == 0 {
+		return p, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return processedImage{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img = applyOrientation(img, orientation)
+	for _, v := range missing {
+		out := img
+		if v.Width != width {
+			scaled := image.NewRGBA(image.Rect(0, 0, v.Width, v.Height))
+			draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+			out = scaled
+		}
+		if err := writeImage(filepath.Join(dir, v.Name), format, out); err != nil {
+			return processedImage{}, err
+		}
+	}
+	return p, nil
+}
+
+// jpegOrientation is the exif orientation of a jpeg from 1 to 8, 1 when it has none
+func jpegOrientation(src []byte) int {
+	if len(src) < 2 || src[0] != 0xff || src[1] != 0xd8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(src) && src[i] == 0xff; {
+		marker := src[i+1]
+		if marker == 0xda || marker == 0xd9 { // exif comes before the image data
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(src[i+2:]))
+		if size < 2 || i+2+size > len(src) {
+			return 1
+		}
+		segment := src[i+4 : i+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first ifd of a tiff header
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	for entry := range int(order.Uint16(tiff[ifd:])) {
+		off := ifd + 2 + entry*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[off+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation turns and mirrors img so it displays upright without exif
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // upside down and mirrored
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // turned anticlockwise, turn it clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // turned clockwise, turn it anticlockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// writeImage encodes to a temp file and renames it so a crash never leaves a
+// truncated file under a content addressed name
+func writeImage(name, format string, img image.Image) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // #nosec G104 -- gone after a successful rename
+
+	if format == "jpeg" {
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(tmp, img)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(name), err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to store %s: %w", filepath.Base(name), err)
+	}
+	return nil
+}
+
+// processImages runs the pipeline over ContentDir/images. other file types are
+// left to be served as they are. images that fail are reported and left out
+func processImages(ctx context.Context, cfg *Config) (imageSet, []ValidationIssue, error) {
+	_, span := otel.Tracer("jake-blog").Start(ctx, "Images.Process")
+	defer span.End()
+
+	images := make(imageSet)
+	var issues []ValidationIssue
+	srcDir := filepath.Join(cfg.ContentDir, "images")
+	if _, err := os.Stat(srcDir); err != nil {
+		return images, nil, nil
+	}
+	dir := imageDir(cfg)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".png", ".jpg", ".jpeg":
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		src, err := os.ReadFile(p) // #nosec G304 -- images are from our own git repo
+		if err != nil {
+			return err
+		}
+		img, err := processImage(dir, src)
+		if err != nil {
+			issues = append(issues, ValidationIssue{Check: checkImage, Source: path.Join("images", name), Message: err.Error()})
+			return nil
+		}
+		images[name] = img
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to process images: %w", err)
+	}
+	span.SetAttributes(attribute.Int("content.images", len(images)))
+	return images, issues, nil
+}
+
+// imageBaseURL is what pages link local images to: the image bucket when the
+// image cache is on, the branch's own path for previews and empty for imageURLPrefix
+func imageBaseURL(cfg *Config) string {
+	if bucketURL := imageBucketURL(cfg); bucketURL != "" {
+		return bucketURL
+	}
+	if cfg.previewBranch != "" {
+		return previewImagePrefix(cfg.previewBranch)
+	}
+	return ""
+}
+
+// imageURL is where a processed file is served from, the image bucket when the
+// image cache is on
+func imageURL(bucketURL, name string) string {
+	if bucketURL != "" {
+		return bucketURL + name
+	}
+	return imageURLPrefix + name
+}
+
+// responsiveImage is the img tag for a processed image. the browser picks a width
+// from srcset and width/height reserve the space before it loads
+func responsiveImage(img processedImage, bucketURL string, alt, title string) string {
+	var b strings.Builder
+	b.WriteString(`<img src="` + imageURL(bucketURL, img.Original.Name) + `" srcset="`)
+	for _, v := range img.Variants {
+		fmt.Fprintf(&b, "%s %dw, ", imageURL(bucketURL, v.Name), v.Width)
+	}
+	fmt.Fprintf(&b, `%s %dw" width="%d" height="%d" alt="%s"`,
+		imageURL(bucketURL, img.Original.Name), img.Original.Width, img.Original.Width, img.Original.Height, html.EscapeString(alt))
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	b.WriteString(` loading="lazy">`)
+	return b.String()
+}
+
+// serveImages swaps the images article pages link to for direct requests
+func (bm *BlogManager) serveImages(images imageSet) {
+	bm.articleMutex.Lock()
+	bm.servedImages = images
+	bm.articleMutex.Unlock()
+}
+
+func (bm *BlogManager) servedImage(name string) (processedImage, bool) {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	img, found := bm.servedImages[name]
+	return img, found
+}
+
+// ImageHandler serves processed images from the image directory. requests for a
+// source image get its metadata free original, anything the pipeline does not
+// handle comes straight from ContentDir/images
+func (s *Server) ImageHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "ImageHandler.Process")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("image.name", strings.TrimPrefix(r.URL.Path, imageURLPrefix)))
+	serveImage(w, r, s.bm, imageURLPrefix, "public, max-age=31536000, immutable")
+}
+
+// serveImage answers for the images of bm served under prefix. cacheControl is
+// sent with the processed files
+func serveImage(w http.ResponseWriter, r *http.Request, bm *BlogManager, prefix, cacheControl string) {
+	name := strings.TrimPrefix(r.URL.Path, prefix)
+	dir := imageDir(bm.Config)
+	if name != "" && !strings.Contains(name, "/") && !strings.HasPrefix(name, ".") {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Mode().IsRegular() {
+			// content addressed so the name changes whenever the bytes do
+			w.Header().Set("Cache-Control", cacheControl)
+			http.ServeFile(w, r, filepath.Join(dir, name))
+			return
+		}
+	}
+	if img, found := bm.servedImage(name); found {
+		http.ServeFile(w, r, filepath.Join(dir, img.Original.Name))
+		return
+	}
+	http.StripPrefix(prefix, http.FileServer(http.Dir(filepath.Join(bm.Config.ContentDir, "images")))).ServeHTTP(w, r)
+}
@@ -0,0 +1,272 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testJPEG is a width x height jpeg carrying an exif segment with gps data
+func testJPEG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: uint8(x), A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	payload := []byte("Exif\x00\x00GPSLatitude 51.5007 GPSLongitude -0.1246")
+	app1 := []byte{0xff, 0xe1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	out := append([]byte{}, buf.Bytes()[:2]...) // SOI
+	out = append(out, app1...)
+	out = append(out, payload...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestPlanImage(t *testing.T) {
+	p := planImage("abc", "jpeg", 1000, 500)
+	require.Equal(t, imageVariant{Name: "abc.jpg", Width: 1000, Height: 500}, p.Original)
+	require.Equal(t, []imageVariant{
+		{Name: "abc-480.jpg", Width: 480, Height: 240},
+		{Name: "abc-960.jpg", Width: 960, Height: 480},
+	}, p.Variants)
+	require.Equal(t, imageVariant{Name: "abc-thumb.jpg", Width: thumbnailWidth, Height: 160}, p.Thumbnail)
+
+	small := planImage("abc", "png", 100, 30)
+	require.Empty(t, small.Variants)
+	require.Equal(t, imageVariant{Name: "abc-thumb.png", Width: 100, Height: 30}, small.Thumbnail)
+}
+
+func TestProcessImage(t *testing.T) {
+	dir := t.TempDir()
+	src := testJPEG(t, 1000, 500)
+	require.Contains(t, string(src), "GPSLatitude")
+
+	p, err := processImage(dir, src)
+	require.NoError(t, err)
+	require.Len(t, p.Hash, 16)
+	require.Len(t, p.Variants, 2)
+
+	for _, v := range p.files() {
+		out, err := os.ReadFile(filepath.Join(dir, v.Name))
+		require.NoError(t, err)
+		require.NotContains(t, string(out), "Exif", "metadata is stripped from %s", v.Name)
+
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		require.NoError(t, err)
+		require.Equal(t, "jpeg", format)
+		require.Equal(t, v.Width, cfg.Width)
+		require.Equal(t, v.Height, cfg.Height)
+	}
+
+	// cached files are reused and missing ones regenerated
+	require.NoError(t, os.Remove(filepath.Join(dir, p.Variants[0].Name)))
+	again, err := processImage(dir, src)
+	require.NoError(t, err)
+	require.Equal(t, p, again)
+	require.FileExists(t, filepath.Join(dir, p.Variants[0].Name))
+
+	_, err = processImage(dir, []byte("not an image"))
+	require.Error(t, err)
+
+	// a frame header claiming a height of 1 and a width of 0, then the start of scan
+	// DecodeConfig stops at
+	zeroWidth := []byte{0xff, 0xd8, 0xff, 0xc0, 0, 11, 8, 0, 1, 0, 0, 1, 1, 0x11, 0, 0xff, 0xda, 0, 8}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(zeroWidth))
+	require.NoError(t, err)
+	require.Zero(t, cfg.Width)
+	_, err = processImage(dir, zeroWidth)
+	require.ErrorIs(t, err, errUnsupportedImage)
+}
+
+// orientedJPEG is a jpeg with a red top left corner tagged with an exif orientation
+func orientedJPEG(t *testing.T, width, height, orientation int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{B: 255, A: 255})
+			if x < width/4 && y < height/4 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	out := append([]byte{}, buf.Bytes()[:2]...)
+	out = append(out, 0xff, 0xe1, byte((len(payload)+2)>>8), byte(len(payload)+2))
+	out = append(out, payload...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestImageOrientation(t *testing.T) {
+	red := func(c color.Color) bool {
+		r, g, b, _ := c.RGBA()
+		return r > 0xc000 && g < 0x4000 && b < 0x4000
+	}
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		redX, redY  int // where the red corner ends up
+	}{
+		{1, 600, 400, 0, 0},
+		{3, 600, 400, 599, 399},
+		{6, 400, 600, 399, 0},
+		{8, 400, 600, 0, 599},
+	}
+
+	for _, tt := range tests {
+		src := orientedJPEG(t, 600, 400, tt.orientation)
+		require.Equal(t, tt.orientation, jpegOrientation(src))
+
+		dir := t.TempDir()
+		p, err := processImage(dir, src)
+		require.NoError(t, err)
+		require.Equal(t, tt.width, p.Original.Width, "orientation %d", tt.orientation)
+		require.Equal(t, tt.height, p.Original.Height, "orientation %d", tt.orientation)
+
+		out, err := os.ReadFile(filepath.Join(dir, p.Original.Name))
+		require.NoError(t, err)
+		require.NotContains(t, string(out), "Exif")
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, tt.width, tt.height), img.Bounds())
+		require.True(t, red(img.At(tt.redX, tt.redY)), "orientation %d", tt.orientation)
+		require.Equal(t, tt.height > tt.width, p.Thumbnail.Height > p.Thumbnail.Width, "variants are upright too")
+	}
+
+	require.Equal(t, 1, jpegOrientation(testJPEG(t, 10, 10)), "exif without a tiff header")
+	require.Equal(t, 1, jpegOrientation(testPNG(t, 10, 10)))
+}
+
+func TestRejectedUpdateKeepsImages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ValidationPolicy = ValidationReject
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	photo := filepath.Join(cfg.ContentDir, "images", "photo.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(photo), 0o755))
+	require.NoError(t, os.WriteFile(photo, testJPEG(t, 600, 400), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "post.md"), []byte("# Post\n\n![photo](images/photo.jpg)"), 0o644))
+
+	bm := NewBlogManager(cfg)
+	require.NoError(t, bm.updateContent())
+	served := bm.images["photo.jpg"].Hash
+
+	// the next update compares against what was last served, not what was rejected
+	require.NoError(t, os.WriteFile(photo, testJPEG(t, 800, 400), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "bad.md"), []byte("---\ntitle: [unclosed\n---\n"), 0o644))
+	require.Error(t, bm.updateContent())
+	require.Equal(t, served, bm.images["photo.jpg"].Hash)
+}
+
+func TestProcessImages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	images := filepath.Join(cfg.ContentDir, "images")
+	require.NoError(t, os.MkdirAll(filepath.Join(images, "trips"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "trips", "beach.jpg"), testJPEG(t, 600, 400), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "logo.png"), testPNG(t, 64, 64), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "broken.png"), []byte("nope"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "anim.gif"), []byte("GIF89a"), 0o644))
+
+	set, issues, err := processImages(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, set, 2)
+	require.Contains(t, set, "trips/beach.jpg")
+	require.Contains(t, set, "logo.png")
+	require.Len(t, issues, 1)
+	require.Equal(t, checkImage, issues[0].Check)
+	require.Equal(t, "images/broken.png", issues[0].Source)
+	require.DirExists(t, cfg.ContentDir+".images")
+
+	require.False(t, imagesChanged(set, set))
+	changed := imageSet{"logo.png": set["logo.png"]}
+	require.True(t, imagesChanged(set, changed))
+}
+
+func TestResponsiveImages(t *testing.T) {
+	images := imageLookup(func(name string) (processedImage, bool) {
+		if name != "cat.jpg" {
+			return processedImage{}, false
+		}
+		return planImage("abc", "jpeg", 1000, 500), true
+	})
+	md := []byte(`![cats & dogs](images/cat.jpg "Cat") ![dog](images/dog.gif)`)
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := newRenderer(cfg, images).Render(md)
+			require.NoError(t, err)
+			require.Contains(t, string(out), `<img src="/article/images/abc.jpg" `+
+				`srcset="/article/images/abc-480.jpg 480w, /article/images/abc-960.jpg 960w, /article/images/abc.jpg 1000w" `+
+				`width="1000" height="500" alt="cats &amp; dogs" title="Cat" loading="lazy">`)
+			require.Contains(t, string(out), `src="images/dog.gif"`, "unprocessed images are left alone")
+
+			cfg.IMAGECACHE = true
+			out, err = newRenderer(cfg, images).Render(md)
+			require.NoError(t, err)
+			require.Contains(t, string(out), `<img src="`+cfg.ImageBucketURL+`abc.jpg" srcset="`+cfg.ImageBucketURL+`abc-480.jpg 480w`)
+			require.Contains(t, string(out), `src="`+cfg.ImageBucketURL+`dog.gif"`)
+		})
+	}
+}
+
+func TestImageHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "images"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "photo.jpg"), testJPEG(t, 500, 250), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "anim.gif"), []byte("GIF89a"), 0o644))
+
+	bm := NewBlogManager(cfg)
+	set, _, err := processImages(context.Background(), cfg)
+	require.NoError(t, err)
+	bm.serveImages(set)
+	s := NewServer(bm, NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	handler := s.SetupRoutes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	photo := set["photo.jpg"]
+	rec := get(imageURLPrefix + photo.Variants[0].Name)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	rec = get(imageURLPrefix + "photo.jpg")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "GPSLatitude", "source names get the stripped original")
+
+	rec = get(imageURLPrefix + "anim.gif")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "GIF89a", rec.Body.String())
+
+	require.Equal(t, http.StatusNotFound, get(imageURLPrefix+"missing.png").Code)
+}
@@ -0,0 +1,238 @@
+package blog
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 -- compared with s3 etags, not used for security
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// with the image cache on pages link images to a bucket instead of this server.
+// every content update publishes the processed images and any other file in
+// ContentDir/images to it. objects are named by content hash or file name and
+// compared by md5 so only new or changed files are uploaded. nothing is deleted
+// as older snapshots may still link to it
+
+// ImageStore is an object store images are published to
+type ImageStore interface {
+	// Objects returns the md5 hex digest of every object by key
+	Objects(ctx context.Context) (map[string]string, error)
+	// Put creates or replaces the object at key. immutable keys are content
+	// addressed so their object never changes and can be cached forever
+	Put(ctx context.Context, key string, body []byte, immutable bool) error
+}
+
+// imageBucketURL is what local images are linked to, empty when they are served
+// by this server
+func imageBucketURL(cfg *Config) string {
+	if !cfg.IMAGECACHE {
+		return ""
+	}
+	return bucketPrefix(cfg.ImageBucketURL)
+}
+
+// bucketPrefix makes sure object keys can be appended to a bucket url
+func bucketPrefix(bucketURL string) string {
+	if bucketURL == "" || strings.HasSuffix(bucketURL, "/") {
+		return bucketURL
+	}
+	return bucketURL + "/"
+}
+
+// imageBucketOrigin is the scheme and host of the bucket url for the csp header
+func imageBucketOrigin(bucketURL string) (string, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an absolute http(s) url", bucketURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// s3ImageStore is an ImageStore backed by an s3 bucket or an s3 compatible store
+type s3ImageStore struct {
+	client *s3.Client
+	bucket string
+}
+
+// newS3ImageStore loads credentials the way the aws sdk always does, from the
+// environment or the shared config files
+func newS3ImageStore(ctx context.Context, cfg *Config) (*s3ImageStore, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.ImageBucketRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.ImageBucketEndpoint != "" {
+			// s3 compatible stores rarely support virtual hosted buckets
+			o.BaseEndpoint = aws.String(cfg.ImageBucketEndpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3ImageStore{client: client, bucket: cfg.ImageBucket}, nil
+}
+
+func (s *s3ImageStore) Objects(ctx context.Context) (map[string]string, error) {
+	objects := make(map[string]string)
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list bucket %s: %w", s.bucket, err)
+		}
+		for _, obj := range page.Contents {
+			// the etag of a single part upload is the quoted md5 of the object
+			objects[aws.ToString(obj.Key)] = strings.Trim(aws.ToString(obj.ETag), `"`)
+		}
+	}
+	return objects, nil
+}
+
+func (s *s3ImageStore) Put(ctx context.Context, key string, body []byte, immutable bool) error {
+	// objects keyed by name are revalidated so edits show up
+	cacheControl := "public, no-cache"
+	if immutable {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(body),
+		ContentType:  aws.String(imageContentType(key)),
+		CacheControl: aws.String(cacheControl),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func imageContentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// imageObject is the file a bucket object is published from
+type imageObject struct {
+	file      string
+	immutable bool // keyed by the hash of its contents
+}
+
+// imageObjects maps each bucket key to the file it is published from. processed
+// images are keyed by their hashed names, anything the pipeline does not handle
+// by its path under ContentDir/images like cachedImageURL links it
+func imageObjects(cfg *Config, images imageSet) (map[string]imageObject, error) {
+	objects := make(map[string]imageObject)
+	dir := imageDir(cfg)
+	for _, img := range images {
+		for _, v := range img.files() {
+			objects[v.Name] = imageObject{file: filepath.Join(dir, v.Name), immutable: true}
+		}
+	}
+
+	srcDir := filepath.Join(cfg.ContentDir, "images")
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if _, processed := images[name]; !processed {
+			objects[name] = imageObject{file: p}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return objects, nil
+}
+
+// syncImages uploads the images missing from the store or that differ from it
+// and returns how many were uploaded
+func syncImages(ctx context.Context, store ImageStore, cfg *Config, images imageSet) (int, error) {
+	ctx, span := otel.Tracer("jake-blog").Start(ctx, "Images.Sync")
+	defer span.End()
+
+	files, err := imageObjects(cfg, images)
+	if err != nil {
+		return 0, err
+	}
+	published, err := store.Objects(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	uploaded := 0
+	for _, key := range keys {
+		body, err := os.ReadFile(files[key].file) // #nosec G304 -- images are from our own git repo
+		if err != nil {
+			return uploaded, fmt.Errorf("failed to read image: %w", err)
+		}
+		sum := md5.Sum(body) // #nosec G401 -- compared with s3 etags
+		if published[key] == hex.EncodeToString(sum[:]) {
+			continue
+		}
+		if err := store.Put(ctx, key, body, files[key].immutable); err != nil {
+			return uploaded, err
+		}
+		uploaded++
+	}
+	span.SetAttributes(attribute.Int("images.objects", len(keys)), attribute.Int("images.uploaded", uploaded))
+	return uploaded, nil
+}
+
+// publishImages syncs the images of an update to the image store, creating the
+// s3 store on first use unless one was configured with WithImageStore
+func (bm *BlogManager) publishImages(ctx context.Context, images imageSet) error {
+	if !bm.Config.IMAGECACHE {
+		return nil
+	}
+	if bm.imageStore == nil {
+		store, err := newS3ImageStore(ctx, bm.Config)
+		if err != nil {
+			return fmt.Errorf("failed to create image store: %w", err)
+		}
+		bm.imageStore = store
+	}
+	uploaded, err := syncImages(ctx, bm.imageStore, bm.Config, images)
+	if err != nil {
+		return fmt.Errorf("failed to publish images: %w", err)
+	}
+	if uploaded > 0 {
+		managerLogger.Info().Msgf("published %d images to %s", uploaded, bm.Config.ImageBucket)
+	}
+	return nil
+}
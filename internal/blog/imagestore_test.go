@@ -0,0 +1,259 @@
+package blog
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memImageStore is an in memory ImageStore that records uploads
+type memImageStore struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	puts      []string
+	immutable map[string]bool
+	err       error
+}
+
+func (m *memImageStore) Objects(ctx context.Context) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects := make(map[string]string, len(m.objects))
+	for key, body := range m.objects {
+		sum := md5.Sum(body)
+		objects[key] = hex.EncodeToString(sum[:])
+	}
+	return objects, nil
+}
+
+func (m *memImageStore) Put(ctx context.Context, key string, body []byte, immutable bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+		m.immutable = make(map[string]bool)
+	}
+	m.objects[key] = body
+	m.immutable[key] = immutable
+	m.puts = append(m.puts, key)
+	return nil
+}
+
+func TestSyncImages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	images := filepath.Join(cfg.ContentDir, "images")
+	require.NoError(t, os.MkdirAll(filepath.Join(images, "trips"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "trips", "beach.jpg"), testJPEG(t, 600, 400), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "anim.gif"), []byte("GIF89a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "trips", "anim.gif"), []byte("GIF89a trip"), 0o644))
+
+	set, _, err := processImages(context.Background(), cfg)
+	require.NoError(t, err)
+	beach := set["trips/beach.jpg"]
+
+	store := &memImageStore{}
+	uploaded, err := syncImages(context.Background(), store, cfg, set)
+	require.NoError(t, err)
+	want := []string{"anim.gif", "trips/anim.gif"}
+	for _, v := range beach.files() {
+		want = append(want, v.Name)
+	}
+	sort.Strings(want)
+	require.Equal(t, want, store.puts, "processed files by hash, others by name, in order")
+	require.Equal(t, len(want), uploaded)
+	require.NotContains(t, store.objects, "beach.jpg", "sources with metadata are not published")
+	require.NotContains(t, store.objects, "trips/beach.jpg")
+	require.Equal(t, []byte("GIF89a trip"), store.objects["trips/anim.gif"], "files with the same name in other directories are kept apart")
+	require.True(t, store.immutable[beach.Original.Name], "processed files are content addressed")
+	require.False(t, store.immutable["anim.gif"], "files keyed by name change in place")
+
+	// unchanged objects are skipped
+	store.puts = nil
+	uploaded, err = syncImages(context.Background(), store, cfg, set)
+	require.NoError(t, err)
+	require.Zero(t, uploaded)
+	require.Empty(t, store.puts)
+
+	// a changed object is uploaded again
+	store.objects["anim.gif"] = []byte("stale")
+	uploaded, err = syncImages(context.Background(), store, cfg, set)
+	require.NoError(t, err)
+	require.Equal(t, 1, uploaded)
+	require.Equal(t, []string{"anim.gif"}, store.puts)
+
+	store.err = errors.New("bucket gone")
+	store.objects = nil
+	_, err = syncImages(context.Background(), store, cfg, set)
+	require.ErrorContains(t, err, "bucket gone")
+}
+
+func TestRejectedUpdatePublishesNothing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.IMAGECACHE = true
+	cfg.ValidationPolicy = ValidationReject
+	cfg.ContentDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "images"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "anim.gif"), []byte("GIF89a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "bad.md"), []byte("---\ntitle: [unclosed\n---\n"), 0o644))
+
+	store := &memImageStore{}
+	bm := NewBlogManager(cfg)
+	bm.imageStore = store
+	require.Error(t, bm.updateContent())
+	require.Empty(t, store.puts)
+}
+
+func TestPublishImages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "images"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "anim.gif"), []byte("GIF89a"), 0o644))
+
+	store := &memImageStore{}
+	bm := NewBlogManager(cfg)
+	bm.imageStore = store
+	require.NoError(t, bm.publishImages(context.Background(), nil))
+	require.Empty(t, store.puts, "nothing is published without the image cache")
+
+	cfg.IMAGECACHE = true
+	require.NoError(t, bm.publishImages(context.Background(), nil))
+	require.Equal(t, []string{"anim.gif"}, store.puts)
+}
+
+// fakeS3 is just enough of the s3 api for s3ImageStore
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "images" {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		type object struct {
+			Key  string
+			ETag string
+		}
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []object
+		}{}
+		for k, body := range f.objects {
+			sum := md5.Sum(body)
+			result.Contents = append(result.Contents, object{Key: k, ETag: `"` + hex.EncodeToString(sum[:]) + `"`})
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut && key != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+		f.headers[key] = r.Header.Clone()
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+func TestS3ImageStore(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	fake := &fakeS3{objects: map[string][]byte{"old.png": []byte("old")}, headers: map[string]http.Header{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.ImageBucket = "images"
+	cfg.ImageBucketEndpoint = srv.URL
+	store, err := newS3ImageStore(context.Background(), cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(context.Background(), "abc.jpg", []byte("jpeg"), true))
+	require.Equal(t, []byte("jpeg"), fake.objects["abc.jpg"])
+	require.Equal(t, "image/jpeg", fake.headers["abc.jpg"].Get("Content-Type"))
+	require.Contains(t, fake.headers["abc.jpg"].Get("Cache-Control"), "immutable")
+	require.NoError(t, store.Put(context.Background(), "trips/anim.gif", []byte("gif"), false))
+	require.Equal(t, "public, no-cache", fake.headers["trips/anim.gif"].Get("Cache-Control"))
+
+	objects, err := store.Objects(context.Background())
+	require.NoError(t, err)
+	sum := md5.Sum([]byte("jpeg"))
+	require.Equal(t, hex.EncodeToString(sum[:]), objects["abc.jpg"])
+	require.Contains(t, objects, "old.png")
+
+	cfg.ImageBucket = "missing"
+	store, err = newS3ImageStore(context.Background(), cfg)
+	require.NoError(t, err)
+	_, err = store.Objects(context.Background())
+	require.Error(t, err)
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	cfg := DefaultConfig()
+	require.Contains(t, contentSecurityPolicy(cfg), "img-src 'self'; ")
+
+	cfg.IMAGECACHE = true
+	cfg.ImageBucketURL = "http://localhost:9000/images/"
+	require.Contains(t, contentSecurityPolicy(cfg), "img-src 'self' http://localhost:9000; ")
+}
+
+func TestImageBucketConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		bucket  string
+		url     string
+		wantErr bool
+	}{
+		{"defaults", "jakeblog-blog-image-cache", DefaultConfig().ImageBucketURL, false},
+		{"s3 compatible", "images", "http://localhost:9000/images", false},
+		{"no bucket", "", "https://images.example.com/", true},
+		{"relative url", "images", "/images/", true},
+		{"not http", "images", "s3://images/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.RepoURL = "git@example.com:blog.git"
+			cfg.Env = "test"
+			cfg.IMAGECACHE = true
+			cfg.ImageBucket = tt.bucket
+			cfg.ImageBucketURL = tt.url
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -2,43 +2,153 @@ package blog
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"html/template"
 	"os"
 	"os/signal"
-	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 )
 
 type Article struct {
-	Title    string
-	FileName string
-	Content  []byte
-	URL      string
-	Date     time.Time
+	Title     string
+	FileName  string
+	Slug      string // url path segment; front matter slug or the file name prefixed with its series
+	Content   []byte // full html page
+	Body      []byte // rendered markdown without the page layout
+	URL       string
+	Date      time.Time // published; front matter date or the first commit of the file
+	Updated   time.Time // front matter updated or the last commit of the file
+	Summary   string    // front matter summary or the first paragraph of text
+	Tags      []string
+	State     ArticleState
+	PublishAt time.Time // embargo for scheduled articles
+	Author    string
+	Canonical string
+
+	Series     string // series slug from the article's directory, empty at the content root
+	SeriesPart int    // position in the series; ties are broken by file name
+
+	WordCount   int
+	ReadingTime int // minutes
+}
+
+// HTML is the rendered markdown for use in theme templates
+func (a Article) HTML() template.HTML {
+	return template.HTML(a.Body) // #nosec G203 -- rendered from our own git repo
 }
 
 type BlogManager struct {
-	Articles     map[string]Article
-	HTMLList     []byte // html snippet - list of articles
-	SiteMap      []byte
-	RSSFeed      []byte
-	Config       *Config
-	articleMutex sync.RWMutex
-	updateChan   chan struct{} // Single channel for all updates
+	Articles        map[string]Article
+	HomePage        []byte // html page served at /
+	HTMLList        []byte // html snippet - list of articles
+	SiteMap         []byte
+	RSSFeed         []byte
+	AtomFeed        []byte
+	JSONFeed        []byte
+	TagList         []byte            // html snippet - every tag with its article count
+	TagArticleLists map[string][]byte // html snippet per tag - list of tagged articles
+	TagRSSFeeds     map[string][]byte // rss feed per tag
+	SeriesPages     map[string][]byte // html index page per series
+	Config          *Config
+	articleMutex    sync.RWMutex
+	updateChan      chan struct{} // Single channel for all updates
+
+	rendered     map[string]Article // every non draft article including unlisted and scheduled
+	series       map[string]Series  // series metadata from _index.md files
+	theme        *Theme             // layouts from the content repo the indexes are built with
+	searchIdx    *searchIndex       // guarded by articleMutex like the rest of the served content
+	servedTheme  *Theme             // theme of the served content, guarded by articleMutex
+	servedImages imageSet           // images of the served content, guarded by articleMutex
+
+	// only touched by the update goroutine
+	fileCache     map[string]Article   // every article from the last update by repo relative path
+	staleFiles    map[string]bool      // cached articles that failed to re-render, retried every update
+	images        imageSet             // processed images of the last successful update
+	renderImages  imageSet             // processed images the renderer links to
+	imageStore    ImageStore           // where images are published with the image cache on
+	lastHead      plumbing.Hash        // content commit of the last successful update
+	firstSeen     map[string]time.Time // LocalOnly created dates by repo relative path, see keepCreated
+	filesRendered metric.Int64Counter
+	filesReused   metric.Int64Counter
+	filesStale    metric.Int64Counter
+	buildMutex    sync.Mutex    // serializes index builds from updates and the scheduler
+	scheduleChan  chan struct{} // re-arms the scheduler after the article set changes
+	reloads       *reloadHub    // dev mode pages waiting for the next update
+
+	snapshots []contentSnapshot // newest first, guarded by buildMutex
+	pinned    *contentSnapshot  // served instead of new content until unpinned, guarded by buildMutex
+
+	reportMutex sync.Mutex
+	report      UpdateReport // outcome of the last update
+
+	renderer   Renderer                                     // markdown engine
+	remoteHead func(context.Context) (plumbing.Hash, error) // ls-remote of the content branch
+	pollMutex  sync.Mutex
+	poll       PollStatus
 }
 
 func NewBlogManager(config *Config) *BlogManager {
-	return &BlogManager{
-		Articles:   make(map[string]Article),
-		Config:     config,
-		updateChan: make(chan struct{}, 1),
+	meter := otel.GetMeterProvider().Meter("jake-blog")
+
+	var filesRendered metric.Int64Counter = noop.Int64Counter{}
+	if c, err := meter.Int64Counter("content.files.rendered",
+		metric.WithDescription("number of markdown files rendered by content updates")); err == nil {
+		filesRendered = c
+	}
+	var filesReused metric.Int64Counter = noop.Int64Counter{}
+	if c, err := meter.Int64Counter("content.files.reused",
+		metric.WithDescription("number of unchanged articles reused by content updates")); err == nil {
+		filesReused = c
 	}
+
+	var filesStale metric.Int64Counter = noop.Int64Counter{}
+	if c, err := meter.Int64Counter("content.files.stale",
+		metric.WithDescription("number of articles served from their previous render after failing to re-render")); err == nil {
+		filesStale = c
+	}
+
+	bm := &BlogManager{
+		Articles:        make(map[string]Article),
+		TagArticleLists: make(map[string][]byte),
+		TagRSSFeeds:     make(map[string][]byte),
+		SeriesPages:     make(map[string][]byte),
+		Config:          config,
+		updateChan:      make(chan struct{}, 1),
+		rendered:        make(map[string]Article),
+		series:          make(map[string]Series),
+		scheduleChan:    make(chan struct{}, 1),
+		reloads:         newReloadHub(),
+		fileCache:       make(map[string]Article),
+		filesRendered:   filesRendered,
+		filesReused:     filesReused,
+		filesStale:      filesStale,
+		staleFiles:      make(map[string]bool),
+	}
+	bm.renderer = newRenderer(config, func(name string) (processedImage, bool) {
+		img, found := bm.renderImages[name]
+		return img, found
+	})
+	bm.remoteHead = func(ctx context.Context) (plumbing.Hash, error) {
+		auth, err := repoAuth(config)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("error loading SSH keys: %w", err)
+		}
+		return remoteHeadHash(ctx, config.RepoURL, config.ContentBranch, auth)
+	}
+	return bm
 }
 
 func (bm *BlogManager) GetArticle(name string) (Article, bool) {
@@ -54,6 +164,64 @@ func (bm *BlogManager) GetRssFeed() []byte {
 	return bm.RSSFeed
 }
 
+func (bm *BlogManager) GetAtomFeed() []byte {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	return bm.AtomFeed
+}
+
+func (bm *BlogManager) GetJSONFeed() []byte {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	return bm.JSONFeed
+}
+
+// GetHomePage is the home page of the served theme, the default one before the first update
+func (bm *BlogManager) GetHomePage() []byte {
+	bm.articleMutex.RLock()
+	page := bm.HomePage
+	bm.articleMutex.RUnlock()
+	if page == nil {
+		page, _ = defaultTheme.HomePage()
+	}
+	return page
+}
+
+func (bm *BlogManager) GetTagList() []byte {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	return bm.TagList
+}
+
+func (bm *BlogManager) GetTagArticleList(tag string) ([]byte, bool) {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	list, exists := bm.TagArticleLists[normalizeTag(tag)]
+	return list, exists
+}
+
+func (bm *BlogManager) GetTagRssFeed(tag string) ([]byte, bool) {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	feed, exists := bm.TagRSSFeeds[normalizeTag(tag)]
+	return feed, exists
+}
+
+func (bm *BlogManager) GetSeriesPage(name string) ([]byte, bool) {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	page, exists := bm.SeriesPages[name]
+	return page, exists
+}
+
+// Search returns the best matching listed articles for a free text query
+func (bm *BlogManager) Search(query string) []searchResult {
+	bm.articleMutex.RLock()
+	idx := bm.searchIdx
+	bm.articleMutex.RUnlock()
+	return idx.search(query, searchMaxResults)
+}
+
 func (bm *BlogManager) GetSiteMap() []byte {
 	bm.articleMutex.RLock()
 	defer bm.articleMutex.RUnlock()
@@ -93,13 +261,9 @@ func (bm *BlogManager) TriggerUpdate() {
 	}
 }
 
-func (bm *BlogManager) extractTitle(filepath string) string {
-	content, err := os.ReadFile(filepath) // #nosec G304 -- file is from trusted source
-	if err != nil {
-		return strings.TrimSuffix(path.Base(filepath), ".md")
-	}
-
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+// first level one heading in the markdown body or the file name if there is none
+func extractTitle(body []byte, fallback string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "# ") {
@@ -107,152 +271,459 @@ func (bm *BlogManager) extractTitle(filepath string) string {
 		}
 	}
 
-	return strings.TrimSuffix(path.Base(filepath), ".md")
+	return fallback
 }
 
-func (bm *BlogManager) createArticleFromFileName(file string) (*Article, error) {
-	artTmpl := `
-        <!DOCTYPE html>
-        <html>
-        <head>
-            <title>%s</title>
-            <link rel="stylesheet" type="text/css" href="/article.css">
-            <link rel="icon" href="/favicon.ico" type="image/x-icon" />
-        </head>
-        <body>
-            <a href="/" class="home-link">Home</a>
-            %s
-        </body>
-        </html>
-    `
-
+func (bm *BlogManager) createArticleFromFileName(file string, history map[string]fileHistory) (*Article, error) {
 	fileName := strings.TrimSuffix(filepath.Base(file), ".md")
-	headerTitle := bm.extractTitle(file)
 
-	lastModified, err := getFileLastModified(bm.Config, filepath.Base(file))
+	raw, err := os.ReadFile(filepath.Clean(file)) // #nosec G304 -- file is from trusted source
+	if err != nil {
+		managerLogger.Error().Str("file", fileName).Msgf("failed to read article: %v", err)
+		return nil, err
+	}
+
+	fm, body, err := parseFrontMatter(raw)
 	if err != nil {
-		managerLogger.Error().Str("file", fileName).Msgf("failed to process article last modified date: %v", err)
+		managerLogger.Error().Str("file", fileName).Msgf("failed to parse front matter: %v", err)
 		return nil, err
 	}
 
-	fileContent, err := markdownToHTML(file, bm.Config.IMAGECACHE)
+	headerTitle := fm.Title
+	if headerTitle == "" {
+		headerTitle = extractTitle(body, fileName)
+	}
+
+	relPath, err := filepath.Rel(bm.Config.ContentDir, file)
+	if err != nil {
+		return nil, fmt.Errorf("article %s is outside the content directory: %w", file, err)
+	}
+
+	// series parts are prefixed with the series so k8s/intro.md and go/intro.md do not collide
+	series := seriesSlug(filepath.Dir(relPath))
+	slug := fileName
+	if series != "" {
+		slug = series + "-" + fileName
+	}
+	if fm.Slug != "" {
+		slug = fm.Slug
+	}
+
+	// front matter wins over git history
+	commits, found := history[filepath.ToSlash(relPath)]
+	date := fm.Date
+	if date.IsZero() && !fm.Publish.IsZero() {
+		date = fm.Publish
+	}
+	if date.IsZero() {
+		if !found {
+			err := fmt.Errorf("file %s not found in git history", relPath)
+			managerLogger.Error().Str("file", fileName).Msgf("failed to process article dates: %v", err)
+			return nil, err
+		}
+		date = commits.Created
+	}
+	updated := fm.Updated
+	if updated.IsZero() {
+		updated = commits.Updated
+	}
+
+	rendered, err := bm.renderer.Render(body)
 	if err != nil {
-		managerLogger.Error().Msgf("failed to convert markdown to html: %v", err)
 		return nil, err
 	}
-	html := fmt.Sprintf(artTmpl, headerTitle, fileContent)
 
-	return &Article{
-		Title:    headerTitle,
-		FileName: fileName,
-		Content:  []byte(html),
-		URL:      fmt.Sprintf("/article/%s", fileName),
-		Date:     lastModified,
-	}, nil
+	summary := fm.Summary
+	if summary == "" {
+		summary = firstParagraph(rendered)
+	}
+	words := countWords(rendered)
+
+	arti := &Article{
+		Title:      headerTitle,
+		FileName:   fileName,
+		Slug:       slug,
+		Body:       rendered,
+		URL:        fmt.Sprintf("/article/%s", slug),
+		Date:       date,
+		Updated:    updated,
+		Summary:    summary,
+		Tags:       normalizeTags(fm.Tags),
+		State:      articleState(fm),
+		PublishAt:  fm.Publish,
+		Author:     fm.Author,
+		Canonical:  fm.Canonical,
+		Series:     series,
+		SeriesPart: fm.Part,
+
+		WordCount:   words,
+		ReadingTime: readingMinutes(words),
+	}
+	return arti, nil
+}
+
+type contentIndex struct {
+	articles map[string]Article
+	homePage []byte
+	htmlList []byte
+	rssFeed  []byte
+	atomFeed []byte
+	jsonFeed []byte
+	siteMap  []byte
+	tags     tagIndex
+	series   seriesIndex
+	search   *searchIndex
+	theme    *Theme
 }
 
-func creatRSSitemFromArticle(art *Article) string {
-	return fmt.Sprintf(`
-		<item>
-		<title> %s </title>
-		<link> %s </link>
-		<pubDate> %s </pubDate>
-		<guid isPermaLink="true"> %s </guid>
-		</item>
-		`,
-		art.Title,
-		fmt.Sprintf("https://jake-henning.com%s", art.URL),
-		art.Date.Format("Mon, 02 Jan 2006 15:04:05 GMT"),
-		fmt.Sprintf("https://jake-henning.com%s", art.URL),
-	)
+// buildContentIndex works out what is live at now. unlisted articles are served
+// but every list, feed and the sitemap only carries published ones
+func buildContentIndex(ctx context.Context, cfg *Config, theme *Theme, rendered map[string]Article, series map[string]Series, now time.Time) contentIndex {
+	if theme == nil {
+		theme = defaultTheme
+	}
+
+	served := make(map[string]Article, len(rendered))
+	listed := make([]Article, 0, len(rendered))
+	for slug, arti := range rendered {
+		switch arti.StateAt(now) {
+		case StatePublished:
+			served[slug] = arti
+			listed = append(listed, arti)
+		case StateUnlisted:
+			served[slug] = arti
+		}
+	}
+
+	sort.Slice(listed, func(i, j int) bool {
+		if listed[i].Date.Equal(listed[j].Date) {
+			return listed[i].Slug < listed[j].Slug
+		}
+		return listed[i].Date.After(listed[j].Date)
+	})
+
+	seriesIdx := buildSeriesIndex(theme, listed, series)
+	for slug, arti := range served {
+		page, err := theme.ArticlePage(&arti, seriesIdx.nav[slug])
+		if err != nil {
+			managerLogger.Error().Str("slug", slug).Msgf("theme failed, using the default article layout: %v", err)
+			page, _ = defaultTheme.ArticlePage(&arti, seriesIdx.nav[slug])
+		}
+		arti.Content = page
+		served[slug] = arti
+	}
+
+	homePage, err := theme.HomePage()
+	if err != nil {
+		managerLogger.Error().Msgf("theme failed, using the default home layout: %v", err)
+		homePage, _ = defaultTheme.HomePage()
+	}
+
+	htmlList, err := theme.ListFragment(listed)
+	if err != nil {
+		managerLogger.Error().Msgf("theme failed, using the default list layout: %v", err)
+		htmlList, _ = defaultTheme.ListFragment(listed)
+	}
+
+	feedArticles := listed[:min(len(listed), cfg.FeedItems)]
+
+	rss, err := buildRSSFeed(siteTitle, siteURL+"/feed/", feedArticles)
+	if err != nil {
+		managerLogger.Error().Msgf("failed to build rss feed: %v", err)
+	}
+	atom, err := buildAtomFeed(feedArticles)
+	if err != nil {
+		managerLogger.Error().Msgf("failed to build atom feed: %v", err)
+	}
+	jsonFeed, err := buildJSONFeed(feedArticles)
+	if err != nil {
+		managerLogger.Error().Msgf("failed to build json feed: %v", err)
+	}
+	siteMap, err := buildSiteMap(listed)
+	if err != nil {
+		managerLogger.Error().Msgf("failed to build sitemap: %v", err)
+	}
+
+	return contentIndex{
+		articles: served,
+		homePage: homePage,
+		htmlList: htmlList,
+		rssFeed:  rss,
+		atomFeed: atom,
+		jsonFeed: jsonFeed,
+		siteMap:  siteMap,
+		tags:     buildTagIndex(theme, listed, cfg.FeedItems),
+		series:   seriesIdx,
+		search:   buildSearchIndex(ctx, listed),
+		theme:    theme,
+	}
+}
+
+func (bm *BlogManager) swapContentIndex(idx contentIndex) {
+	bm.articleMutex.Lock()
+	bm.Articles = idx.articles
+	bm.HomePage = idx.homePage
+	bm.HTMLList = idx.htmlList
+	bm.RSSFeed = idx.rssFeed
+	bm.AtomFeed = idx.atomFeed
+	bm.JSONFeed = idx.jsonFeed
+	bm.SiteMap = idx.siteMap
+	bm.TagList = idx.tags.list
+	bm.TagArticleLists = idx.tags.articleLists
+	bm.TagRSSFeeds = idx.tags.feeds
+	bm.SeriesPages = idx.series.pages
+	bm.searchIdx = idx.search
+	bm.servedTheme = idx.theme
+	bm.articleMutex.Unlock()
+}
+
+// rebuild and swap the indexes from the articles rendered by the last update
+func (bm *BlogManager) rebuildIndexes() {
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+
+	idx := buildContentIndex(context.Background(), bm.Config, bm.theme, bm.rendered, bm.series, time.Now())
+	bm.swapContentIndex(idx)
+	managerLogger.Info().Msgf("indexes rebuilt: serving %d articles", len(idx.articles))
+}
+
+// filesToRender works out which content files need rendering. with a previous
+// successful update it reuses every cached article the git diff since then did
+// not touch. without one, or when the diff fails, everything is rendered
+func (bm *BlogManager) filesToRender(head plumbing.Hash, relPaths []string) (map[string]bool, bool) {
+	render := make(map[string]bool, len(relPaths))
+	for _, rel := range relPaths {
+		render[rel] = true
+	}
+
+	if bm.lastHead.IsZero() || head.IsZero() {
+		return render, false
+	}
+
+	changed := map[string]bool{}
+	if head != bm.lastHead {
+		var err error
+		changed, err = changedFiles(bm.Config, bm.lastHead, head)
+		if err != nil {
+			managerLogger.Warn().Msgf("falling back to a full rebuild: %v", err)
+			return render, false
+		}
+	}
+
+	for _, rel := range relPaths {
+		_, cached := bm.fileCache[rel]
+		// files that failed last time are retried even when unchanged
+		render[rel] = changed[rel] || !cached || bm.staleFiles[rel]
+	}
+	return render, true
 }
 
 func (bm *BlogManager) updateContent() error {
+	ctx, span := otel.Tracer("jake-blog").Start(context.Background(), "BlogManager.updateContent")
+	defer span.End()
+
 	err := FetchMarkdownRepo(bm.Config)
 	if err != nil {
+		span.SetAttributes(attribute.String("error", "fetch failed"))
 		return fmt.Errorf("error cloning md repository: %w", err)
 	}
 
-	files, err := filepath.Glob(filepath.Join(bm.Config.ContentDir, "*.md"))
+	head, err := headHash(bm.Config)
 	if err != nil {
-		return fmt.Errorf("could not find md files: %w", err)
+		return fmt.Errorf("could not read content HEAD: %w", err)
 	}
+	span.SetAttributes(attribute.String("content.head", head.String()))
 
-	newArticles := make(map[string]Article)
-	var links []string
-	var rssBuilder strings.Builder
-	var mapBuilder strings.Builder
-
-	mapBuilder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	mapBuilder.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
-
-	mapBuilder.WriteString(` <url>`)
-	mapBuilder.WriteString(`<loc>https://jake-henning.com/</loc>`)
-	mapBuilder.WriteString(`</url>`)
+	files, err := findMarkdownFiles(bm.Config.ContentDir)
+	if err != nil {
+		return fmt.Errorf("could not find md files: %w", err)
+	}
 
-	mapBuilder.WriteString(` <url>`)
-	mapBuilder.WriteString(`<loc>https://jake-henning.com/feed/</loc>`)
-	mapBuilder.WriteString(`</url>`)
+	relPaths := make(map[string]string, len(files)) // file -> repo relative path
+	allPaths := make([]string, 0, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(bm.Config.ContentDir, file); err == nil {
+			relPaths[file] = filepath.ToSlash(rel)
+			allPaths = append(allPaths, filepath.ToSlash(rel))
+		}
+	}
 
-	rssBuilder.WriteString(`
-     <rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
-      <channel>
-        <title> Jacob Henning's Blog </title>
-        <link> https://jake-henning.com </link>
-        <description> The personal blog of Jacob Henning </description>
-        <atom:link href="https://jake-henning.com/feed/" rel="self" type="application/rss+xml" />
-   `)
+	images, imageIssues, err := processImages(ctx, bm.Config)
+	if err != nil {
+		return err
+	}
+	render, incremental := bm.filesToRender(head, allPaths)
+	if imagesChanged(bm.images, images) {
+		// image tags carry the processed file names and sizes
+		for rel := range render {
+			render[rel] = true
+		}
+		incremental = false
+	}
+	bm.renderImages = images
+	renderPaths := make([]string, 0, len(render))
+	for rel, needed := range render {
+		if needed {
+			renderPaths = append(renderPaths, rel)
+		}
+	}
+	history, err := scanFileHistory(bm.Config, renderPaths)
+	if err != nil {
+		return fmt.Errorf("could not scan git history: %w", err)
+	}
+	if bm.Config.LocalOnly {
+		bm.keepCreated(history)
+	}
 
+	fileCache := make(map[string]Article, len(files))
+	rendered := make(map[string]Article)
+	sources := make(map[string]string)    // slug -> repo relative file for reports
+	seriesDirs := make(map[string]string) // series slug -> repo relative directory
+	issues := imageIssues
+	stale := make(map[string]bool) // files serving their previous render
+	series := make(map[string]Series)
+	drafts, renderedFiles, reusedFiles := 0, 0, 0
 	for _, file := range files {
-		fArt, err := bm.createArticleFromFileName(file)
-		if err != nil {
-			managerLogger.Warn().Msgf("failed to load article %s: %v", file, err)
+		if filepath.Base(file) == seriesIndexFile {
+			s, err := bm.loadSeries(file)
+			if err != nil {
+				managerLogger.Warn().Msgf("failed to load series index %s: %v", file, err)
+				continue
+			}
+			if issue, dup := claimSeries(seriesDirs, s.Slug, relPaths[file]); dup {
+				issues = append(issues, issue)
+				continue
+			}
+			if s.Slug != "" {
+				series[s.Slug] = s
+			}
 			continue
 		}
-		newArticles[fArt.FileName] = *fArt
 
-		rssBuilder.WriteString(creatRSSitemFromArticle(fArt))
-
-		mapBuilder.WriteString(` <url>`)
-		mapBuilder.WriteString(`<loc>https://jake-henning.com/article/`)
-		mapBuilder.WriteString(fArt.FileName)
-		mapBuilder.WriteString(`</loc>`)
-		mapBuilder.WriteString(`</url>`)
+		rel := relPaths[file]
+		var fArt *Article
+		if cached, found := bm.fileCache[rel]; found && !render[rel] {
+			fArt = &cached
+			reusedFiles++
+		} else {
+			fresh, err := bm.createArticleFromFileName(file, history)
+			switch {
+			case err == nil:
+				fArt = fresh
+				renderedFiles++
+			case found:
+				// keep serving the last good render rather than unpublishing on a transient error
+				issues = append(issues, renderIssue(rel, err))
+				managerLogger.Warn().Str("file", rel).Msgf("serving stale article after failed re-render: %v", err)
+				fArt = &cached
+				stale[rel] = true
+			default:
+				issues = append(issues, renderIssue(rel, err))
+				continue
+			}
+		}
+		fileCache[rel] = *fArt
 
+		if fArt.State == StateDraft {
+			drafts++
+			continue
+		}
+		if issue, dup := claimSeries(seriesDirs, fArt.Series, rel); dup {
+			issues = append(issues, issue)
+			continue
+		}
+		if prev, dup := sources[fArt.Slug]; dup {
+			issues = append(issues, ValidationIssue{
+				Check: checkDuplicateSlug, Source: rel,
+				Message: fmt.Sprintf("slug %q already used by %s: skipping", fArt.Slug, prev),
+			})
+			continue
+		}
+		sources[fArt.Slug] = rel
+		rendered[fArt.Slug] = *fArt
 	}
 
-	keys := make([]string, 0, len(newArticles))
-	for k := range newArticles {
-		keys = append(keys, k)
+	bm.filesRendered.Add(ctx, int64(renderedFiles))
+	bm.filesReused.Add(ctx, int64(reusedFiles))
+	bm.filesStale.Add(ctx, int64(len(stale)))
+	span.SetAttributes(
+		attribute.Bool("content.incremental", incremental),
+		attribute.Int("content.files.rendered", renderedFiles),
+		attribute.Int("content.files.reused", reusedFiles),
+		attribute.Int("content.files.stale", len(stale)),
+	)
+
+	theme, err := loadTheme(bm.Config.ContentDir)
+	if err != nil {
+		issues = append(issues, ValidationIssue{Check: checkTheme, Source: themeDir, Message: err.Error()})
+		theme = defaultTheme
 	}
 
-	sort.Slice(keys, func(i, j int) bool {
-		return newArticles[keys[i]].Date.After(newArticles[keys[j]].Date)
-	})
+	issues = append(issues, validateContent(bm.Config, rendered, sources)...)
+	report := UpdateReport{
+		Commit:   head.String(),
+		Time:     time.Now(),
+		Rendered: renderedFiles,
+		Reused:   reusedFiles,
+		Drafts:   drafts,
+		Issues:   issues,
+	}
+	for rel := range stale {
+		report.Stale = append(report.Stale, rel)
+	}
+	sort.Strings(report.Stale)
+	blocking := 0
+	for i, issue := range issues {
+		if stale[issue.Source] && bm.staleFiles[issue.Source] {
+			issues[i].Carried = true
+		} else {
+			blocking++
+		}
+		managerLogger.Warn().Str("check", issue.Check).Str("source", issue.Source).Bool("carried", issues[i].Carried).Msg(issue.Message)
+	}
+	span.SetAttributes(attribute.Int("content.validation.issues", len(issues)))
+	if blocking > 0 && bm.Config.ValidationPolicy == ValidationReject {
+		report.Rejected = true
+		bm.setUpdateReport(report)
+		span.SetAttributes(attribute.String("error", "validation failed"))
+		return fmt.Errorf("update to %s rejected: %d validation issues", head, blocking)
+	}
 
-	for _, key := range keys {
-		arti := newArticles[key]
+	// published once accepted and before any page links to them
+	if err := bm.publishImages(ctx, images); err != nil {
+		return err
+	}
 
-		// html list
-		links = append(links, fmt.Sprintf(`<li><a href="/article/%s">%s</a> -- <span class="date">%s</span> </li>`,
-			arti.FileName, arti.Title, arti.Date.Format("Jan 2, 2006")))
+	bm.buildMutex.Lock()
+	idx := buildContentIndex(ctx, bm.Config, theme, rendered, series, time.Now())
+	bm.recordSnapshot(contentSnapshot{head: head, built: time.Now(), rendered: rendered, series: series, theme: theme, images: images, index: idx})
+	pinned := bm.pinned
+	if pinned == nil {
+		bm.rendered = rendered
+		bm.series = series
+		bm.theme = theme
+		bm.swapContentIndex(idx)
+		bm.serveImages(images)
+	}
+	bm.buildMutex.Unlock()
 
+	if pinned != nil {
+		span.SetAttributes(attribute.String("content.pinned", pinned.head.String()))
+		managerLogger.Warn().Msgf("content is pinned to %s: built %s but not serving it", pinned.head, head)
 	}
 
-	rssBuilder.WriteString(`
-      </channel>
-    </rss>
-    `)
+	bm.fileCache = fileCache
+	bm.staleFiles = stale
+	bm.images = images
+	bm.lastHead = head
 
-	mapBuilder.WriteString(`</urlset>`)
+	bm.rescheduleArticles()
 
-	bm.articleMutex.Lock()
-	bm.Articles = newArticles
-	bm.HTMLList = []byte(strings.Join(links, "<br/>"))
-	bm.RSSFeed = []byte(rssBuilder.String())
-	bm.SiteMap = []byte(mapBuilder.String())
-	bm.articleMutex.Unlock()
+	report.Serving = len(idx.articles)
+	bm.setUpdateReport(report)
+	bm.reloads.publish()
 
-	managerLogger.Info().Msgf("content update succedeed: loaded %d articles", len(newArticles))
+	managerLogger.Info().Msgf("content update succedeed: loaded %d articles serving %d skipped %d drafts (rendered %d reused %d)",
+		len(rendered), len(idx.articles), drafts, renderedFiles, reusedFiles)
 	return nil
 }
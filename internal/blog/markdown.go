@@ -1,62 +1,354 @@
 package blog
 
 import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
 
 	bf "github.com/russross/blackfriday/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
+// markdown engines for Config.MarkdownEngine
+const (
+	EngineBlackfriday = "blackfriday"
+	EngineGoldmark    = "goldmark"
+)
+
+// Renderer turns article markdown into html
+type Renderer interface {
+	Render(markdown []byte) ([]byte, error)
+}
+
+// NewRenderer returns the engine selected by cfg. unknown engines are rejected by
+// Config.Validate so they fall back to blackfriday here
+func NewRenderer(cfg *Config) Renderer {
+	return newRenderer(cfg, nil)
+}
+
+// newRenderer links images the pipeline processed to their responsive variants
+func newRenderer(cfg *Config, images imageLookup) Renderer {
+	bucketURL := imageBaseURL(cfg)
+	if cfg.MarkdownEngine == EngineGoldmark {
+		return newGoldmarkRenderer(bucketURL, cfg.TOCThreshold, images)
+	}
+	return &blackfridayRenderer{bucketURL: bucketURL, tocThreshold: cfg.TOCThreshold, images: images}
+}
+
+// imageLookup finds a processed image by its path under ContentDir/images
+type imageLookup func(name string) (processedImage, bool)
+
+// find the processed image an img src refers to
+func (l imageLookup) find(dest, bucketURL string) (processedImage, bool) {
+	if l == nil {
+		return processedImage{}, false
+	}
+	name, local := localImageName(dest, bucketURL)
+	if !local {
+		return processedImage{}, false
+	}
+	return l(name)
+}
+
+// cachedImageURL points a local image at the image bucket, where files the
+// pipeline does not process keep their path under ContentDir/images. other urls
+// are left alone
+func cachedImageURL(bucketURL, dest string) string {
+	if name, local := localImageName(dest, bucketURL); local {
+		return bucketURL + name
+	}
+	return dest
+}
+
 type jakeRenderer struct {
-	cacheURL string
 	*bf.HTMLRenderer
+	bucketURL string
+	images    imageLookup
 }
 
 func (r *jakeRenderer) RenderNode(w io.Writer, node *bf.Node, entering bool) bf.WalkStatus {
 	if node.Type == bf.Image && entering {
-		originalDest := string(node.Destination)
-		if !strings.HasPrefix(originalDest, "https://") {
-			fn := strings.Split(originalDest, "/")
-			node.Destination = []byte(r.cacheURL + fn[1])
+		if img, found := r.images.find(string(node.Destination), r.bucketURL); found {
+			_, _ = io.WriteString(w, responsiveImage(img, r.bucketURL, blackfridayText(node), string(node.Title)))
+			return bf.SkipChildren
+		}
+		if r.bucketURL != "" {
+			node.Destination = []byte(cachedImageURL(r.bucketURL, string(node.Destination)))
 		}
 	}
+	if node.Type == bf.CodeBlock {
+		ci := parseCodeInfo(string(node.Info))
+		if lexer := codeLexer(ci.lang); lexer != nil {
+			out, err := highlightCode(lexer, ci, node.Literal)
+			if err == nil {
+				_, _ = w.Write(out)
+				return bf.GoToNext
+			}
+			mdLogger.Warn().Msgf("rendering code block without highlighting: %v", err)
+		}
+	}
+	if node.Type == bf.Heading && !entering && node.HeadingID != "" {
+		_, _ = io.WriteString(w, headingAnchor(node.HeadingID))
+	}
 	return r.HTMLRenderer.RenderNode(w, node, entering)
 }
 
-func markdownToHTML(markdownPath string, imageCache bool) (string, error) {
-	escapedPath := filepath.Clean(markdownPath)
-	mdFile, err := os.Open(escapedPath)
-	if err != nil {
-		return "", err
+// blackfridayHeadings gives every heading a slug id, explicit {#id}s included so
+// they are de-duplicated too
+func blackfridayHeadings(doc *bf.Node) []tocEntry {
+	slugger := newHeadingSlugger()
+	var headings []tocEntry
+	doc.Walk(func(node *bf.Node, entering bool) bf.WalkStatus {
+		if node.Type != bf.Heading || !entering {
+			return bf.GoToNext
+		}
+		text := blackfridayText(node)
+		id := node.HeadingID
+		if id == "" {
+			id = text
+		}
+		node.HeadingID = slugger.slug(id)
+		headings = append(headings, tocEntry{Level: node.Level, ID: node.HeadingID, Text: text})
+		return bf.SkipChildren
+	})
+	return headings
+}
+
+// blackfridayText is the plain text of node
+func blackfridayText(node *bf.Node) string {
+	var text strings.Builder
+	node.Walk(func(child *bf.Node, entering bool) bf.WalkStatus {
+		if entering && (child.Type == bf.Text || child.Type == bf.Code) {
+			text.Write(child.Literal)
+		}
+		return bf.GoToNext
+	})
+	return text.String()
+}
+
+type blackfridayRenderer struct {
+	bucketURL    string
+	tocThreshold int
+	images       imageLookup
+}
+
+func (r *blackfridayRenderer) Render(markdown []byte) ([]byte, error) {
+	// bf.Run renders with the common flags, the image cache renderer never had them
+	params := bf.HTMLRendererParameters{Flags: bf.CommonHTMLFlags}
+	if r.bucketURL != "" {
+		params = bf.HTMLRendererParameters{}
+	}
+	cRenderer := &jakeRenderer{
+		HTMLRenderer: bf.NewHTMLRenderer(params),
+		bucketURL:    r.bucketURL,
+		images:       r.images,
 	}
-	defer mdFile.Close()
 
-	fileInfo, err := mdFile.Stat()
-	if err != nil {
-		return "", err
+	// bf.Run without the convenience so heading ids can be set between parsing and rendering
+	doc := bf.New(bf.WithRenderer(cRenderer), bf.WithExtensions(bf.CommonExtensions)).Parse(markdown)
+	headings := blackfridayHeadings(doc)
+
+	var buf bytes.Buffer
+	cRenderer.RenderHeader(&buf, doc)
+	doc.Walk(func(node *bf.Node, entering bool) bf.WalkStatus {
+		return cRenderer.RenderNode(&buf, node, entering)
+	})
+	cRenderer.RenderFooter(&buf, doc)
+	return insertTOC(buf.Bytes(), headings, r.tocThreshold), nil
+}
+
+// imageRenderer is jakeRenderer's image handling for goldmark
+type imageRenderer struct {
+	fallback  renderer.NodeRendererFunc
+	bucketURL string
+	images    imageLookup
+}
+
+func newImageRenderer(bucketURL string, images imageLookup) *imageRenderer {
+	defaults := nodeRendererFuncs{}
+	html.NewRenderer(html.WithUnsafe()).RegisterFuncs(defaults)
+	return &imageRenderer{fallback: defaults[ast.KindImage], bucketURL: bucketURL, images: images}
+}
+
+func (r *imageRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindImage, r.render)
+}
+
+func (r *imageRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Image)
+	if entering {
+		if img, found := r.images.find(string(n.Destination), r.bucketURL); found {
+			_, _ = w.WriteString(responsiveImage(img, r.bucketURL, goldmarkText(n, source), string(n.Title)))
+			return ast.WalkSkipChildren, nil
+		}
+		if r.bucketURL != "" {
+			n.Destination = []byte(cachedImageURL(r.bucketURL, string(n.Destination)))
+		}
 	}
+	return r.fallback(w, source, node, entering)
+}
 
-	size := fileInfo.Size()
-	buffer := make([]byte, size)
+// codeBlockRenderer highlights fenced code blocks for goldmark and leaves the rest
+// to the default html renderer
+type codeBlockRenderer struct {
+	fallback renderer.NodeRendererFunc
+}
 
-	_, err = mdFile.Read(buffer)
+func newCodeBlockRenderer() *codeBlockRenderer {
+	defaults := nodeRendererFuncs{}
+	html.NewRenderer().RegisterFuncs(defaults)
+	return &codeBlockRenderer{fallback: defaults[ast.KindFencedCodeBlock]}
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.render)
+}
+
+func (r *codeBlockRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.FencedCodeBlock)
+	var info string
+	if n.Info != nil {
+		info = string(n.Info.Segment.Value(source))
+	}
+	ci := parseCodeInfo(info)
+	lexer := codeLexer(ci.lang)
+	if lexer == nil {
+		return r.fallback(w, source, node, entering)
+	}
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+	out, err := highlightCode(lexer, ci, code.Bytes())
 	if err != nil {
-		return "", err
+		// the plain block is written whole here as the exit above skips the fallback
+		mdLogger.Warn().Msgf("rendering code block without highlighting: %v", err)
+		if _, err := r.fallback(w, source, node, true); err != nil {
+			return ast.WalkStop, err
+		}
+		return r.fallback(w, source, node, false)
 	}
+	_, err = w.Write(out)
+	return ast.WalkContinue, err
+}
 
-	htmlRenderer := bf.NewHTMLRenderer(bf.HTMLRendererParameters{})
-	cRenderer := &jakeRenderer{
-		HTMLRenderer: htmlRenderer,
-		cacheURL:     "https://jakeblog-blog-image-cache.s3.us-east-1.amazonaws.com/",
+// headingAnchorRenderer adds the hover anchor to goldmark headings
+type headingAnchorRenderer struct {
+	fallback renderer.NodeRendererFunc
+}
+
+func newHeadingAnchorRenderer() *headingAnchorRenderer {
+	defaults := nodeRendererFuncs{}
+	html.NewRenderer().RegisterFuncs(defaults)
+	return &headingAnchorRenderer{fallback: defaults[ast.KindHeading]}
+}
+
+func (r *headingAnchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.render)
+}
+
+func (r *headingAnchorRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if id, ok := node.AttributeString("id"); ok && !entering {
+		if id, ok := id.([]byte); ok {
+			_, _ = w.WriteString(headingAnchor(string(id)))
+		}
+	}
+	return r.fallback(w, source, node, entering)
+}
+
+// goldmarkHeadings gives every heading a slug id from its text, the same ids
+// blackfriday gets
+func goldmarkHeadings(doc ast.Node, source []byte) []tocEntry {
+	slugger := newHeadingSlugger()
+	var headings []tocEntry
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		text := goldmarkText(heading, source)
+		id := slugger.slug(text)
+		heading.SetAttributeString("id", []byte(id))
+		headings = append(headings, tocEntry{Level: heading.Level, ID: id, Text: text})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// goldmarkText is the plain text of n. typographer entities are decoded
+func goldmarkText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.WriteString(stdhtml.UnescapeString(string(t.Value)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// nodeRendererFuncs collects the funcs a NodeRenderer registers
+type nodeRendererFuncs map[ast.NodeKind]renderer.NodeRendererFunc
+
+func (f nodeRendererFuncs) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	f[kind] = fn
+}
+
+type goldmarkRenderer struct {
+	md           goldmark.Markdown
+	tocThreshold int
+}
+
+func newGoldmarkRenderer(bucketURL string, tocThreshold int, images imageLookup) *goldmarkRenderer {
+	return &goldmarkRenderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM, extension.Footnote, extension.Typographer),
+			// posts are from our own repo and may embed html like blackfriday allows
+			goldmark.WithRendererOptions(
+				html.WithUnsafe(),
+				// ahead of the default html renderer at 1000
+				renderer.WithNodeRenderers(
+					util.Prioritized(newCodeBlockRenderer(), 100),
+					util.Prioritized(newHeadingAnchorRenderer(), 100),
+					util.Prioritized(newImageRenderer(bucketURL, images), 100),
+				),
+			),
+		),
+		tocThreshold: tocThreshold,
 	}
+}
+
+func (r *goldmarkRenderer) Render(markdown []byte) ([]byte, error) {
+	// Convert split in two so heading ids can be set between parsing and rendering
+	doc := r.md.Parser().Parse(text.NewReader(markdown))
+	headings := goldmarkHeadings(doc, markdown)
 
-	var html []byte
-	if imageCache {
-		html = bf.Run(buffer, bf.WithRenderer(cRenderer))
-	} else {
-		html = bf.Run(buffer)
+	var buf bytes.Buffer
+	if err := r.md.Renderer().Render(&buf, markdown, doc); err != nil {
+		return nil, fmt.Errorf("failed to render markdown: %w", err)
 	}
-	return string(html), nil
+	return insertTOC(buf.Bytes(), headings, r.tocThreshold), nil
 }
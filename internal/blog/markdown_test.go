@@ -1,12 +1,60 @@
 package blog
 
 import (
-	"os"
-	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
-var markdownSink string
+func TestGoldmarkGFM(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MarkdownEngine = EngineGoldmark
+
+	md := "| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"~~gone~~ and https://example.com\n\n" +
+		"- [x] done\n- [ ] todo\n\n" +
+		"a claim[^1]\n\n[^1]: the source\n\n" +
+		"\"quoted\" -- text\n"
+
+	out, err := NewRenderer(cfg).Render([]byte(md))
+	require.NoError(t, err)
+	html := string(out)
+	require.Contains(t, html, "<table>")
+	require.Contains(t, html, "<del>gone</del>")
+	require.Contains(t, html, `<a href="https://example.com">https://example.com</a>`)
+	require.Contains(t, html, `<input checked="" disabled="" type="checkbox">`)
+	require.Contains(t, html, `class="footnotes"`)
+	require.Contains(t, html, "&ldquo;quoted&rdquo; &ndash; text")
+}
+
+func TestRendererImageCache(t *testing.T) {
+	md := []byte("![local](images/cat.png) ![remote](https://example.com/dog.png) ![nested](images/pets/cat.png)")
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.Contains(t, string(out), `src="images/cat.png"`)
+
+			cfg.IMAGECACHE = true
+			out, err = NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.Contains(t, string(out), `src="`+cfg.ImageBucketURL+`cat.png"`)
+			require.Contains(t, string(out), `src="https://example.com/dog.png"`)
+			require.Contains(t, string(out), `src="`+cfg.ImageBucketURL+`pets/cat.png"`)
+
+			cfg.ImageBucketURL = "http://localhost:9000/images"
+			out, err = NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.Contains(t, string(out), `src="http://localhost:9000/images/cat.png"`)
+		})
+	}
+}
+
+var markdownSink []byte
 
 func BenchmarkMarkdowntoHtml(b *testing.B) {
 	content := `# Testing and Deploying my Personal Blog
@@ -61,17 +109,18 @@ Github actions is new for me. I prefer gitlab approach of using an arbitrary con
 ## Emerging architecture
 `
 
-	mdPath := filepath.Join(b.TempDir(), "test-article.md")
-	if err := os.WriteFile(mdPath, []byte(content), 0644); err != nil {
-		b.Fatal(err)
-	}
+	md := []byte(content)
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		b.Run(engine, func(b *testing.B) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+			renderer := NewRenderer(cfg)
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		result, err := markdownToHTML(mdPath, false)
-		if err != nil {
-			b.Fatal(err)
-		}
-		markdownSink = result
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				markdownSink, _ = renderer.Render(md)
+			}
+		})
 	}
 }
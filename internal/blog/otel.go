@@ -74,6 +74,12 @@ func (e *MetricsExporter) Export(_ context.Context, metrics *metricdata.Resource
 						e.localTem.costUpdateSuccess.Store(point.Value)
 					case "blog.cost.update.failure":
 						e.localTem.costUpdateFailure.Store(point.Value)
+					case "webhook.accepted":
+						e.localTem.webhookAccepted.Store(point.Value)
+					case "webhook.rejected":
+						if _, found := point.Attributes.Value(attribute.Key("reason")); !found {
+							e.localTem.webhookRejected.Store(point.Value)
+						}
 					}
 				}
 			case metricdata.Histogram[float64]:
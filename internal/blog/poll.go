@@ -0,0 +1,100 @@
+package blog
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	pollMaxBackoff = 30 * time.Minute
+	pollTimeout    = 30 * time.Second // bound on a single ls-remote
+)
+
+// PollStatus is the outcome of the most recent remote check
+type PollStatus struct {
+	LastCheck  time.Time
+	RemoteHash plumbing.Hash
+	Failures   int // consecutive failed checks, reset by a success
+}
+
+func (bm *BlogManager) GetPollStatus() PollStatus {
+	bm.pollMutex.Lock()
+	defer bm.pollMutex.Unlock()
+	return bm.poll
+}
+
+// pollDelay doubles the interval for every consecutive failure up to pollMaxBackoff.
+// backed off delays are jittered over their upper half so a flapping remote is not
+// hit on a fixed beat
+func pollDelay(interval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		return interval
+	}
+	delay := interval
+	for i := 0; i < failures && delay < pollMaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, max(pollMaxBackoff, interval))
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// checkRemote compares the remote content branch with the local checkout and
+// triggers an update when they differ
+func (bm *BlogManager) checkRemote(ctx context.Context) error {
+	ctx, span := otel.Tracer("jake-blog").Start(ctx, "BlogManager.checkRemote")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	remote, err := bm.remoteHead(ctx)
+	if err == nil {
+		var local plumbing.Hash
+		local, err = headHash(bm.Config)
+		if err == nil && remote != local {
+			managerLogger.Info().Msgf("remote content branch moved %s -> %s: triggering update", local, remote)
+			span.SetAttributes(attribute.Bool("poll.changed", true))
+			bm.TriggerUpdate()
+		}
+	}
+
+	bm.pollMutex.Lock()
+	defer bm.pollMutex.Unlock()
+	bm.poll.LastCheck = time.Now()
+	if err != nil {
+		bm.poll.Failures++
+		span.SetAttributes(attribute.String("error", err.Error()))
+		return err
+	}
+	bm.poll.RemoteHash = remote
+	bm.poll.Failures = 0
+	return nil
+}
+
+func (bm *BlogManager) listenForRemote(ctx context.Context) {
+	if bm.Config.LocalOnly || bm.Config.PollInterval <= 0 {
+		return
+	}
+	interval := time.Duration(bm.Config.PollInterval) * time.Second
+
+	go func() {
+		delay := interval
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			if err := bm.checkRemote(ctx); err != nil {
+				managerLogger.Warn().Msgf("failed to check remote for new content: %v", err)
+			}
+			delay = pollDelay(interval, bm.GetPollStatus().Failures)
+		}
+	}()
+}
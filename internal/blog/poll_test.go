@@ -0,0 +1,89 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollDelay(t *testing.T) {
+	interval := time.Minute
+	require.Equal(t, interval, pollDelay(interval, 0))
+
+	for failures := 1; failures < 10; failures++ {
+		want := min(interval<<failures, pollMaxBackoff)
+		for range 20 {
+			got := pollDelay(interval, failures)
+			require.GreaterOrEqual(t, got, want/2)
+			require.LessOrEqual(t, got, want)
+		}
+	}
+
+	// an interval longer than the cap is never shortened by backing off
+	require.GreaterOrEqual(t, pollDelay(time.Hour, 3), 30*time.Minute)
+	require.LessOrEqual(t, pollDelay(time.Hour, 3), time.Hour)
+}
+
+func TestRemoteHeadHash(t *testing.T) {
+	dir, commit := testRepo(t)
+	commit(day(1), map[string]string{"a.md": "a"})
+	head := commit(day(2), map[string]string{"a.md": "a edited"})
+
+	// PlainInit starts on master
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(
+		plumbing.NewHashReference(plumbing.NewBranchReferenceName("main"), head)))
+
+	hash, err := remoteHeadHash(context.Background(), dir, "main", nil)
+	require.NoError(t, err)
+	require.Equal(t, head, hash)
+
+	_, err = remoteHeadHash(context.Background(), dir, "missing", nil)
+	require.Error(t, err)
+}
+
+func TestCheckRemote(t *testing.T) {
+	dir, commit := testRepo(t)
+	local := commit(day(1), map[string]string{"a.md": "a"})
+
+	cfg := DefaultConfig()
+	cfg.ContentDir = dir
+	bm := NewBlogManager(cfg)
+
+	triggered := func() bool {
+		select {
+		case <-bm.updateChan:
+			return true
+		default:
+			return false
+		}
+	}
+
+	remote := local
+	var remoteErr error
+	bm.remoteHead = func(context.Context) (plumbing.Hash, error) { return remote, remoteErr }
+
+	require.NoError(t, bm.checkRemote(context.Background()))
+	require.False(t, triggered(), "remote matches local")
+	require.Equal(t, local, bm.GetPollStatus().RemoteHash)
+
+	remoteErr = errors.New("connection refused")
+	require.Error(t, bm.checkRemote(context.Background()))
+	require.Error(t, bm.checkRemote(context.Background()))
+	require.Equal(t, 2, bm.GetPollStatus().Failures)
+	require.False(t, triggered())
+
+	remote, remoteErr = plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"), nil
+	require.NoError(t, bm.checkRemote(context.Background()))
+	require.True(t, triggered(), "remote moved ahead")
+	status := bm.GetPollStatus()
+	require.Zero(t, status.Failures)
+	require.Equal(t, remote, status.RemoteHash)
+	require.False(t, status.LastCheck.IsZero())
+}
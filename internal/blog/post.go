@@ -0,0 +1,68 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// slugs of new posts are safe in urls and file names on every platform
+var postSlugRe = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// new posts are drafts so pushing one early never publishes it
+const postTemplate = `---
+title: %s
+date: %s
+draft: true
+tags: []
+---
+# %s
+
+`
+
+// NewPost scaffolds a draft post for slug in the content directory and returns
+// its path
+func NewPost(envPrefix, slug string, opts ...ConfigOption) (string, error) {
+	cfg, err := loadConfig(envPrefix, opts...)
+	if err != nil {
+		return "", err
+	}
+	return scaffoldPost(cfg.ContentDir, slug, time.Now())
+}
+
+func scaffoldPost(dir, slug string, now time.Time) (string, error) {
+	if !postSlugRe.MatchString(slug) {
+		return "", fmt.Errorf("slug %q must be lower case letters and digits joined by dashes", slug)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+
+	words := strings.Split(slug, "-")
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	title := strings.Join(words, " ")
+
+	name := filepath.Join(dir, slug+".md")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) // #nosec G302 G304 -- a post in our own content dir
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("post %s already exists", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create post: %w", err)
+	}
+	_, err = fmt.Fprintf(f, postTemplate, title, now.Format(time.DateOnly), title)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write post: %w", err)
+	}
+	return name, nil
+}
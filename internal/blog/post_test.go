@@ -0,0 +1,36 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaffoldPost(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "content")
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	name, err := scaffoldPost(dir, "hello-go-2", now)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "hello-go-2.md"), name)
+
+	raw, err := os.ReadFile(name)
+	require.NoError(t, err)
+	fm, body, err := parseFrontMatter(raw)
+	require.NoError(t, err)
+	require.Equal(t, "Hello Go 2", fm.Title)
+	require.True(t, fm.Draft, "new posts are drafts")
+	require.Equal(t, "2026-03-04", fm.Date.Format(time.DateOnly))
+	require.Equal(t, "# Hello Go 2\n\n", string(body))
+
+	_, err = scaffoldPost(dir, "hello-go-2", now)
+	require.ErrorContains(t, err, "already exists")
+
+	for _, slug := range []string{"", "Hello", "two--dashes", "-lead", "../escape", "a/b", "with space"} {
+		_, err := scaffoldPost(dir, slug, now)
+		require.Error(t, err, slug)
+	}
+}
@@ -0,0 +1,132 @@
+package blog
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const previewCookie = "preview_token"
+
+// previewConfig is cfg pointed at branch with its own checkout next to the production
+// one. images are processed into the checkout's own image directory and served
+// under the preview path, never published to the production bucket
+func previewConfig(cfg *Config, branch string) *Config {
+	c := *cfg
+	c.ContentBranch = branch
+	c.ContentDir = filepath.Join(filepath.Clean(cfg.ContentDir)+".preview", branch)
+	c.PreviewBranches = ""
+	c.IMAGECACHE = false
+	c.ImageDir = ""
+	c.previewBranch = branch
+	return &c
+}
+
+// previewImagePrefix is where the images of a preview branch are served
+func previewImagePrefix(branch string) string {
+	return "/preview/" + branch + imageURLPrefix
+}
+
+// newPreviewManagers builds a BlogManager for every allow listed preview branch
+func newPreviewManagers(cfg *Config) map[string]*BlogManager {
+	previews := make(map[string]*BlogManager)
+	for _, branch := range cfg.PreviewBranchList() {
+		previews[branch] = NewBlogManager(previewConfig(cfg, branch))
+	}
+	return previews
+}
+
+// previewAuthorized accepts the token as a query parameter and remembers it in a
+// cookie so reloading a shared preview link keeps working
+func (s *Server) previewAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	want := []byte(s.bm.Config.PreviewToken)
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(token), want) != 1 {
+			return false
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     previewCookie,
+			Value:    token,
+			Path:     "/preview/",
+			HttpOnly: true,
+			Secure:   s.bm.Config.HTTPSOn,
+			SameSite: http.SameSiteStrictMode,
+		})
+		return true
+	}
+
+	cookie, err := r.Cookie(previewCookie)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), want) == 1
+}
+
+// PreviewHandler serves /preview/{branch}/article/{slug} from the branch's BlogManager
+func (s *Server) PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "PreviewHandler.Process")
+	defer span.End()
+
+	// previews are unreviewed drafts, keep them out of caches and search engines
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	if !s.previewAuthorized(w, r) {
+		span.SetAttributes(attribute.String("error", "unauthorized"))
+		s.reqBlockedInstrument("PREVIEW_TOKEN", r.Context())
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	unescaped, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/preview/"))
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "invalid url encoding"))
+		http.Error(w, "invalid url encoding", http.StatusBadRequest)
+		return
+	}
+
+	if branch, rest, _ := strings.Cut(unescaped, "/"); strings.HasPrefix("/"+rest, imageURLPrefix) {
+		if bm, exists := s.previews[branch]; exists {
+			span.SetAttributes(attribute.String("preview.branch", branch))
+			serveImage(w, r, bm, previewImagePrefix(branch), "private, max-age=31536000, immutable")
+			return
+		}
+	}
+
+	branch, articleName, ok := strings.Cut(unescaped, "/article/")
+	if !ok || articleName == "" || strings.Contains(articleName, "/") {
+		span.SetAttributes(attribute.String("error", "not a preview article path"))
+		s.errorPage(w, http.StatusNotFound, "page not found")
+		return
+	}
+	span.SetAttributes(
+		attribute.String("preview.branch", branch),
+		attribute.String("article.name", articleName),
+	)
+
+	bm, exists := s.previews[branch]
+	if !exists {
+		span.SetAttributes(attribute.String("error", "branch not previewed"))
+		s.errorPage(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	article, exists := bm.GetArticle(articleName)
+	if !exists {
+		span.SetAttributes(attribute.String("error", "article not found"))
+		s.errorPage(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write(article.Content) // #nosec G705 -- content is from our own git repo, not user input
+	if err != nil {
+		serverLogger.Error().Msgf("failed to send preview article to client: %v", err)
+		span.SetAttributes(attribute.String("error", "write failed"))
+	}
+}
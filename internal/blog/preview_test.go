@@ -0,0 +1,169 @@
+package blog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPreviewToken = "0123456789abcdef"
+
+func previewServer(t *testing.T) (*Server, http.Handler) {
+	cfg := DefaultConfig()
+	cfg.PreviewBranches = "draft-post, next"
+	cfg.PreviewToken = testPreviewToken
+	cfg.WebhookSecret = "hook-secret"
+
+	s := NewServer(NewBlogManager(cfg), NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	s.previews = newPreviewManagers(cfg)
+	s.previews["draft-post"].Articles["new-post"] = Article{Slug: "new-post", Content: []byte("<p>draft</p>")}
+	return s, s.SetupRoutes()
+}
+
+func TestPreviewHandler(t *testing.T) {
+	_, handler := previewServer(t)
+
+	tests := []struct {
+		name   string
+		target string
+		cookie string
+		want   int
+	}{
+		{"token in query", "/preview/draft-post/article/new-post?token=" + testPreviewToken, "", http.StatusOK},
+		{"token in cookie", "/preview/draft-post/article/new-post", testPreviewToken, http.StatusOK},
+		{"no token", "/preview/draft-post/article/new-post", "", http.StatusUnauthorized},
+		{"bad token", "/preview/draft-post/article/new-post?token=guess", "", http.StatusUnauthorized},
+		{"bad cookie", "/preview/draft-post/article/new-post", "guess", http.StatusUnauthorized},
+		{"branch not allow listed", "/preview/main/article/new-post", testPreviewToken, http.StatusNotFound},
+		{"missing article", "/preview/next/article/new-post", testPreviewToken, http.StatusNotFound},
+		{"not an article path", "/preview/draft-post/", testPreviewToken, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: previewCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.want, rec.Code)
+			require.Equal(t, "private, no-store", rec.Header().Get("Cache-Control"))
+			if tt.want == http.StatusOK {
+				require.Equal(t, "<p>draft</p>", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPreviewWebhookTriggersBranch(t *testing.T) {
+	s, handler := previewServer(t)
+
+	body := `{"ref":"refs/heads/next"}`
+	req := httptest.NewRequest(http.MethodPost, "/hooks/content", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+signBody("hook-secret", body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, s.previews["next"].updateChan, 1)
+	require.Empty(t, s.previews["draft-post"].updateChan)
+	require.Empty(t, s.bm.updateChan)
+}
+
+func TestPreviewConfig(t *testing.T) {
+	valid := func() *Config {
+		cfg := DefaultConfig()
+		cfg.RepoURL = "git@example.com:blog.git"
+		cfg.Env = "test"
+		cfg.PreviewBranches = "draft-post,next,draft-post"
+		cfg.PreviewToken = testPreviewToken
+		return cfg
+	}
+
+	cfg := valid()
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, []string{"draft-post", "next"}, cfg.PreviewBranchList())
+
+	cfg.IMAGECACHE = true
+	cfg.ImageDir = "/var/cache/images"
+	preview := previewConfig(cfg, "next")
+	require.Equal(t, "next", preview.ContentBranch)
+	require.NotEqual(t, cfg.ContentDir, preview.ContentDir)
+	require.Empty(t, preview.PreviewBranchList())
+	require.False(t, preview.IMAGECACHE, "unreviewed images are never published")
+	require.Equal(t, preview.ContentDir+".images", imageDir(preview))
+	require.Equal(t, "/preview/next/article/images/", imageBaseURL(preview))
+	require.Equal(t, cfg.ImageBucketURL, imageBaseURL(cfg))
+
+	cfg = valid()
+	cfg.PreviewToken = "short"
+	require.Error(t, cfg.Validate())
+
+	cfg = valid()
+	cfg.PreviewBranches = "feature/nested"
+	require.Error(t, cfg.Validate())
+
+	cfg = valid()
+	cfg.LocalOnly = true
+	require.Error(t, cfg.Validate())
+
+	cfg = valid()
+	cfg.ContentBranch = "bad..branch"
+	require.Error(t, cfg.Validate())
+}
+
+func TestPreviewImages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = filepath.Join(t.TempDir(), "content")
+	cfg.PreviewBranches = "draft-post"
+	cfg.PreviewToken = testPreviewToken
+	s := NewServer(NewBlogManager(cfg), NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	s.previews = newPreviewManagers(cfg)
+	handler := s.SetupRoutes()
+
+	preview := s.previews["draft-post"]
+	images := filepath.Join(preview.Config.ContentDir, "images")
+	require.NoError(t, os.MkdirAll(images, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "photo.jpg"), testJPEG(t, 500, 250), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(images, "anim.gif"), []byte("GIF89a"), 0o644))
+	set, _, err := processImages(context.Background(), preview.Config)
+	require.NoError(t, err)
+	preview.serveImages(set)
+	photo := set["photo.jpg"]
+
+	out, err := newRenderer(preview.Config, func(name string) (processedImage, bool) {
+		img, found := set[name]
+		return img, found
+	}).Render([]byte("![photo](images/photo.jpg) ![anim](images/anim.gif)"))
+	require.NoError(t, err)
+	require.Contains(t, string(out), `src="/preview/draft-post/article/images/`+photo.Original.Name+`"`)
+	require.Contains(t, string(out), `src="/preview/draft-post/article/images/anim.gif"`)
+
+	get := func(target string, token bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token {
+			req.AddCookie(&http.Cookie{Name: previewCookie, Value: testPreviewToken})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/preview/draft-post/article/images/"+photo.Original.Name, true)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "private, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	require.Equal(t, http.StatusOK, get("/preview/draft-post/article/images/anim.gif", true).Code)
+	require.Equal(t, http.StatusUnauthorized, get("/preview/draft-post/article/images/anim.gif", false).Code)
+	require.Equal(t, http.StatusNotFound, get("/article/images/anim.gif", false).Code, "branch images are not in production")
+}
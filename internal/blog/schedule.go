@@ -0,0 +1,100 @@
+package blog
+
+import (
+	"context"
+	"time"
+)
+
+type ArticleState int
+
+const (
+	StatePublished ArticleState = iota // served, listed and in the feeds
+	StateUnlisted                      // served by url but left out of the list, feeds and sitemap
+	StateScheduled                     // published once PublishAt passes
+	StateDraft                         // never served
+)
+
+func (s ArticleState) String() string {
+	switch s {
+	case StatePublished:
+		return "published"
+	case StateUnlisted:
+		return "unlisted"
+	case StateScheduled:
+		return "scheduled"
+	case StateDraft:
+		return "draft"
+	default:
+		return "unknown"
+	}
+}
+
+// draft wins over unlisted which wins over a publish timestamp
+func articleState(fm FrontMatter) ArticleState {
+	switch {
+	case fm.Draft:
+		return StateDraft
+	case fm.Unlisted:
+		return StateUnlisted
+	case !fm.Publish.IsZero():
+		return StateScheduled
+	default:
+		return StatePublished
+	}
+}
+
+// StateAt resolves scheduled articles whose embargo has passed to published
+func (a *Article) StateAt(now time.Time) ArticleState {
+	if a.State == StateScheduled && !now.Before(a.PublishAt) {
+		return StatePublished
+	}
+	return a.State
+}
+
+// earliest embargo that has not passed yet, zero if nothing is scheduled
+func (bm *BlogManager) nextScheduledPublish(now time.Time) time.Time {
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+
+	var next time.Time
+	for _, arti := range bm.rendered {
+		if arti.StateAt(now) != StateScheduled {
+			continue
+		}
+		if next.IsZero() || arti.PublishAt.Before(next) {
+			next = arti.PublishAt
+		}
+	}
+	return next
+}
+
+// publish scheduled articles once their embargo passes. the indexes are rebuilt
+// from the already rendered articles so git is not pulled again
+func (bm *BlogManager) listenForSchedule(ctx context.Context) {
+	go func() {
+		for {
+			var publish <-chan time.Time
+			if next := bm.nextScheduledPublish(time.Now()); !next.IsZero() {
+				managerLogger.Info().Msgf("next scheduled article publishes at %s", next.Format(time.RFC3339))
+				publish = time.After(time.Until(next))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-bm.scheduleChan:
+				// content changed, re-arm against the new article set
+			case <-publish:
+				managerLogger.Info().Msg("scheduled article embargo passed: rebuilding indexes")
+				bm.rebuildIndexes()
+			}
+		}
+	}()
+}
+
+func (bm *BlogManager) rescheduleArticles() {
+	select {
+	case bm.scheduleChan <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,54 @@
+package blog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildContentIndexStates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	rendered := map[string]Article{
+		"published": {Title: "Published", Slug: "published", URL: "/article/published", Date: now.Add(-time.Hour), State: StatePublished},
+		"unlisted":  {Title: "Unlisted", Slug: "unlisted", URL: "/article/unlisted", Date: now.Add(-time.Hour), State: StateUnlisted},
+		"embargoed": {Title: "Embargoed", Slug: "embargoed", URL: "/article/embargoed", Date: now.Add(time.Hour), State: StateScheduled, PublishAt: now.Add(time.Hour)},
+		"released":  {Title: "Released", Slug: "released", URL: "/article/released", Date: now.Add(-time.Minute), State: StateScheduled, PublishAt: now.Add(-time.Minute)},
+	}
+
+	idx := buildContentIndex(context.Background(), DefaultConfig(), nil, rendered, nil, now)
+
+	require.Contains(t, idx.articles, "published")
+	require.Contains(t, idx.articles, "unlisted")
+	require.Contains(t, idx.articles, "released")
+	require.NotContains(t, idx.articles, "embargoed")
+
+	for _, listing := range [][]byte{idx.htmlList, idx.rssFeed, idx.siteMap} {
+		require.Contains(t, string(listing), "/article/published")
+		require.Contains(t, string(listing), "/article/released")
+		require.NotContains(t, string(listing), "/article/unlisted")
+		require.NotContains(t, string(listing), "/article/embargoed")
+	}
+
+	// once the embargo passes the scheduled article is live
+	later := buildContentIndex(context.Background(), DefaultConfig(), nil, rendered, nil, now.Add(2*time.Hour))
+	require.Contains(t, later.articles, "embargoed")
+	require.Contains(t, string(later.htmlList), "/article/embargoed")
+}
+
+func TestNextScheduledPublish(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	bm := NewBlogManager(DefaultConfig())
+	require.True(t, bm.nextScheduledPublish(now).IsZero())
+
+	bm.rendered = map[string]Article{
+		"past":   {State: StateScheduled, PublishAt: now.Add(-time.Hour)},
+		"soon":   {State: StateScheduled, PublishAt: now.Add(time.Hour)},
+		"later":  {State: StateScheduled, PublishAt: now.Add(2 * time.Hour)},
+		"normal": {State: StatePublished},
+	}
+	require.Equal(t, now.Add(time.Hour), bm.nextScheduledPublish(now))
+}
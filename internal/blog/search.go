@@ -0,0 +1,257 @@
+package blog
+
+import (
+	"context"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	searchTitleBoost   = 5  // a title match is worth this many body matches
+	searchSnippetWidth = 80 // characters either side of the first match
+	searchMaxResults   = 20
+	searchMaxQueryLen  = 256
+)
+
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"with": true,
+}
+
+type searchPosting struct {
+	slug   string
+	weight int // body occurrences plus boosted title occurrences
+}
+
+type searchDoc struct {
+	title string
+	url   string
+	text  string // plain article text for snippets
+}
+
+// searchIndex is an inverted index from stemmed terms to the articles containing them.
+// it is immutable once built and swapped with the rest of the content
+type searchIndex struct {
+	postings map[string][]searchPosting
+	docs     map[string]searchDoc
+}
+
+type searchResult struct {
+	Title   string
+	URL     string
+	Snippet string // html with matches wrapped in <mark>
+	score   float64
+}
+
+// stem strips common english suffixes so "deploying", "deployed" and "deploys" share a term
+func stem(word string) string {
+	for _, suffix := range []string{"ingly", "edly", "ing", "ies", "ied", "ed", "es", "ly", "s"} {
+		if !strings.HasSuffix(word, suffix) || len(word)-len(suffix) < 3 {
+			continue
+		}
+		base := strings.TrimSuffix(word, suffix)
+		switch suffix {
+		case "ies", "ied":
+			return base + "y"
+		case "s":
+			if strings.HasSuffix(base, "s") { // class, glass
+				return word
+			}
+		}
+		return base
+	}
+	return word
+}
+
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func searchTerms(text string) []string {
+	words := searchWords(text)
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if len(w) < 2 || searchStopWords[w] {
+			continue
+		}
+		terms = append(terms, stem(w))
+	}
+	return terms
+}
+
+// stripHTML drops tags and unescapes entities leaving the readable text
+func stripHTML(markup string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range markup {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			b.WriteRune(' ')
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(b.String())), " ")
+}
+
+func buildSearchIndex(ctx context.Context, articles []Article) *searchIndex {
+	_, span := otel.Tracer("jake-blog").Start(ctx, "SearchIndex.Build")
+	defer span.End()
+
+	idx := &searchIndex{
+		postings: make(map[string][]searchPosting),
+		docs:     make(map[string]searchDoc, len(articles)),
+	}
+
+	for i := range articles {
+		arti := &articles[i]
+		text := stripHTML(string(arti.Body))
+		idx.docs[arti.Slug] = searchDoc{title: arti.Title, url: arti.URL, text: text}
+
+		weights := make(map[string]int)
+		for _, term := range searchTerms(arti.Title) {
+			weights[term] += searchTitleBoost
+		}
+		for _, term := range searchTerms(text) {
+			weights[term]++
+		}
+		for term, weight := range weights {
+			idx.postings[term] = append(idx.postings[term], searchPosting{slug: arti.Slug, weight: weight})
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("search.documents", len(idx.docs)),
+		attribute.Int("search.terms", len(idx.postings)),
+	)
+	return idx
+}
+
+// search ranks articles by tf-idf over the query terms
+func (idx *searchIndex) search(query string, limit int) []searchResult {
+	if idx == nil {
+		return nil
+	}
+
+	terms := searchTerms(query)
+	scores := make(map[string]float64)
+	seen := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(idx.docs))/float64(len(postings)))
+		for _, p := range postings {
+			scores[p.slug] += float64(p.weight) * idf
+		}
+	}
+
+	results := make([]searchResult, 0, len(scores))
+	for slug, score := range scores {
+		doc := idx.docs[slug]
+		results = append(results, searchResult{
+			Title:   doc.title,
+			URL:     doc.url,
+			Snippet: searchSnippet(doc.text, seen),
+			score:   score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].URL < results[j].URL
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// searchSnippet cuts a window of text around the first matching word and marks every match in it
+func searchSnippet(text string, terms map[string]bool) string {
+	matches := func(word string) bool {
+		w := strings.ToLower(word)
+		return terms[stem(w)] && !searchStopWords[w]
+	}
+
+	start, end := 0, min(len(text), 2*searchSnippetWidth)
+	matchStart, matchEnd := 0, 0
+	for _, word := range wordSpans(text) {
+		if matches(text[word[0]:word[1]]) {
+			matchStart, matchEnd = word[0], word[1]
+			start = max(0, matchStart-searchSnippetWidth)
+			end = min(len(text), matchEnd+searchSnippetWidth)
+			break
+		}
+	}
+	// stripHTML leaves single spaces between words so cutting on them keeps runes whole
+	for start > 0 && start < matchStart && text[start-1] != ' ' {
+		start++
+	}
+	for end < len(text) && end > matchEnd && text[end] != ' ' {
+		end--
+	}
+
+	window := text[start:end]
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("&hellip; ")
+	}
+	last := 0
+	for _, word := range wordSpans(window) {
+		if !matches(window[word[0]:word[1]]) {
+			continue
+		}
+		b.WriteString(html.EscapeString(window[last:word[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(window[word[0]:word[1]]))
+		b.WriteString("</mark>")
+		last = word[1]
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	if end < len(text) {
+		b.WriteString(" &hellip;")
+	}
+	return b.String()
+}
+
+// byte offsets of each run of letters and digits
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
@@ -0,0 +1,67 @@
+package blog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"deploying": "deploy",
+		"deployed":  "deploy",
+		"deploys":   "deploy",
+		"policies":  "policy",
+		"class":     "class",
+		"go":        "go",
+	}
+	for word, expected := range tests {
+		require.Equal(t, expected, stem(word), word)
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	require.Equal(t, "Title a &amp; b", stripHTML("<h1>Title</h1><p>a &amp;amp; b</p>"))
+}
+
+func TestSearchRanking(t *testing.T) {
+	articles := []Article{
+		{Title: "Deploying with Ansible", Slug: "ansible", URL: "/article/ansible", Body: []byte("<p>playbooks for the blog</p>")},
+		{Title: "Fishing", Slug: "fishing", URL: "/article/fishing", Body: []byte("<p>I deployed a lure once while deploying a boat</p>")},
+		{Title: "Unrelated", Slug: "unrelated", URL: "/article/unrelated", Body: []byte("<p>nothing to see</p>")},
+	}
+	idx := buildSearchIndex(context.Background(), articles)
+
+	results := idx.search("deploy", searchMaxResults)
+	require.Len(t, results, 2)
+	require.Equal(t, "/article/ansible", results[0].URL, "title matches are boosted")
+	require.Equal(t, "/article/fishing", results[1].URL)
+	require.Contains(t, results[1].Snippet, "<mark>deployed</mark>")
+	require.Contains(t, results[1].Snippet, "<mark>deploying</mark>")
+
+	require.Empty(t, idx.search("the", searchMaxResults), "stop words do not match")
+	require.Empty(t, idx.search("kubernetes", searchMaxResults))
+}
+
+func TestSearchSnippetEscapes(t *testing.T) {
+	snippet := searchSnippet("use <script> to deploy", map[string]bool{"deploy": true})
+	require.Equal(t, "use &lt;script&gt; to <mark>deploy</mark>", snippet)
+}
+
+func TestSearchHandlerEscapes(t *testing.T) {
+	bm := NewBlogManager(DefaultConfig())
+	bm.searchIdx = buildSearchIndex(context.Background(), []Article{
+		{Title: "Deploy <fast>", Slug: "deploy", URL: `/article/a"b<c>`, Body: []byte("<p>deploy it</p>")},
+	})
+	s := NewServer(bm, nil)
+	require.NotNil(t, s)
+
+	rec := httptest.NewRecorder()
+	s.SetupRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=deploy", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `href="/article/a&#34;b&lt;c&gt;"`)
+	require.Contains(t, rec.Body.String(), "Deploy &lt;fast&gt;")
+}
@@ -0,0 +1,182 @@
+package blog
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Series is a directory of articles in the content repo. title and intro come
+// from an optional _index.md in the directory
+type Series struct {
+	Slug    string
+	Title   string
+	Summary string
+	Intro   []byte // rendered _index.md body
+}
+
+// IntroHTML is the rendered intro for use in theme templates
+func (s Series) IntroHTML() template.HTML {
+	return template.HTML(s.Intro) // #nosec G203 -- rendered from our own git repo
+}
+
+const seriesIndexFile = "_index.md"
+
+// series slug for a directory relative to the content root. nested directories
+// are joined with hyphens so k8s/networking becomes k8s-networking
+func seriesSlug(relDir string) string {
+	relDir = filepath.ToSlash(relDir)
+	if relDir == "." || relDir == "" {
+		return ""
+	}
+	return normalizeTag(strings.ReplaceAll(relDir, "/", "-"))
+}
+
+// claimSeries records the directory of source, a slash separated path relative to
+// the content root, as the owner of its series slug. a different directory that
+// slugs the same way, like a-b/ and a/b/, is reported so its files can be skipped
+func claimSeries(owners map[string]string, slug, source string) (ValidationIssue, bool) {
+	if slug == "" {
+		return ValidationIssue{}, false
+	}
+	dir := path.Dir(source)
+	if prev, found := owners[slug]; found && prev != dir {
+		return ValidationIssue{
+			Check: checkDuplicateSlug, Source: source,
+			Message: fmt.Sprintf("series %q already used by %s/: skipping", slug, prev),
+		}, true
+	}
+	owners[slug] = dir
+	return ValidationIssue{}, false
+}
+
+// every markdown file under root in lexical order. hidden directories like .git are skipped
+func findMarkdownFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(p) == ".md" {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (bm *BlogManager) loadSeries(indexFile string) (Series, error) {
+	relPath, err := filepath.Rel(bm.Config.ContentDir, indexFile)
+	if err != nil {
+		return Series{}, err
+	}
+	dir := filepath.Dir(relPath)
+
+	raw, err := os.ReadFile(filepath.Clean(indexFile)) // #nosec G304 -- file is from trusted source
+	if err != nil {
+		return Series{}, err
+	}
+
+	fm, body, err := parseFrontMatter(raw)
+	if err != nil {
+		return Series{}, err
+	}
+
+	title := fm.Title
+	if title == "" {
+		title = extractTitle(body, filepath.Base(dir))
+	}
+
+	intro, err := bm.renderer.Render(body)
+	if err != nil {
+		return Series{}, err
+	}
+
+	return Series{
+		Slug:    seriesSlug(dir),
+		Title:   title,
+		Summary: fm.Summary,
+		Intro:   intro,
+	}, nil
+}
+
+type seriesIndex struct {
+	pages map[string][]byte // full html index page per series
+	nav   map[string]string // prev/next navigation per article slug
+}
+
+// buildSeriesIndex orders the listed parts of every series and builds the
+// series pages and the navigation injected into each part
+func buildSeriesIndex(theme *Theme, listed []Article, meta map[string]Series) seriesIndex {
+	parts := make(map[string][]*Article)
+	for i := range listed {
+		if listed[i].Series == "" {
+			continue
+		}
+		parts[listed[i].Series] = append(parts[listed[i].Series], &listed[i])
+	}
+
+	idx := seriesIndex{
+		pages: make(map[string][]byte, len(parts)),
+		nav:   make(map[string]string),
+	}
+
+	for slug, arts := range parts {
+		sort.Slice(arts, func(i, j int) bool {
+			if arts[i].SeriesPart != arts[j].SeriesPart {
+				return arts[i].SeriesPart < arts[j].SeriesPart
+			}
+			return arts[i].FileName < arts[j].FileName
+		})
+
+		series, found := meta[slug]
+		if !found {
+			series = Series{Slug: slug, Title: slug}
+		}
+		seriesURL := fmt.Sprintf("/series/%s", slug)
+
+		partList := make([]Article, 0, len(arts))
+		for _, arti := range arts {
+			partList = append(partList, *arti)
+		}
+		page, err := theme.SeriesPage(&series, partList)
+		if err != nil {
+			managerLogger.Error().Str("series", slug).Msgf("theme failed, using the default series layout: %v", err)
+			page, _ = defaultTheme.SeriesPage(&series, partList)
+		}
+		idx.pages[slug] = page
+
+		for i, arti := range arts {
+			var nav strings.Builder
+			nav.WriteString(`<nav class="series-nav">`)
+			nav.WriteString(fmt.Sprintf(`<a href="%s" class="series-link">%s</a> <span class="series-part">part %d of %d</span>`,
+				seriesURL, html.EscapeString(series.Title), i+1, len(arts)))
+			if i > 0 {
+				nav.WriteString(fmt.Sprintf(`<a href="%s" class="series-prev">&larr; %s</a>`, arts[i-1].URL, html.EscapeString(arts[i-1].Title)))
+			}
+			if i < len(arts)-1 {
+				nav.WriteString(fmt.Sprintf(`<a href="%s" class="series-next">%s &rarr;</a>`, arts[i+1].URL, html.EscapeString(arts[i+1].Title)))
+			}
+			nav.WriteString(`</nav>`)
+			idx.nav[arti.Slug] = nav.String()
+		}
+	}
+
+	return idx
+}
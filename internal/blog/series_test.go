@@ -0,0 +1,96 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesSlug(t *testing.T) {
+	require.Equal(t, "", seriesSlug("."))
+	require.Equal(t, "k8s", seriesSlug("k8s"))
+	require.Equal(t, "k8s-networking", seriesSlug(filepath.Join("K8s", "Networking")))
+}
+
+func TestFindMarkdownFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"a.md", "series/b.md", "series/nested/c.md", ".git/d.md", "images/e.png"} {
+		p := filepath.Join(root, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte("# x"), 0o644))
+	}
+
+	files, err := findMarkdownFiles(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(root, "a.md"),
+		filepath.Join(root, "series/b.md"),
+		filepath.Join(root, "series/nested/c.md"),
+	}, files)
+}
+
+func TestBuildSeriesIndex(t *testing.T) {
+	listed := []Article{
+		{Title: "Third", Slug: "third", FileName: "c", URL: "/article/third", Series: "k8s", SeriesPart: 3},
+		{Title: "First", Slug: "first", FileName: "a", URL: "/article/first", Series: "k8s", SeriesPart: 1},
+		{Title: "Second", Slug: "second", FileName: "b", URL: "/article/second", Series: "k8s", SeriesPart: 2},
+		{Title: "Standalone", Slug: "standalone", URL: "/article/standalone"},
+	}
+	meta := map[string]Series{"k8s": {Slug: "k8s", Title: "Kubernetes"}}
+
+	idx := buildSeriesIndex(defaultTheme, listed, meta)
+
+	require.Len(t, idx.pages, 1)
+	page := string(idx.pages["k8s"])
+	require.Contains(t, page, "Kubernetes")
+	require.Less(t, strings.Index(page, "/article/first"), strings.Index(page, "/article/second"))
+	require.Less(t, strings.Index(page, "/article/second"), strings.Index(page, "/article/third"))
+
+	require.NotContains(t, idx.nav, "standalone")
+	require.NotContains(t, idx.nav["first"], "series-prev")
+	require.Contains(t, idx.nav["first"], `href="/article/second" class="series-next"`)
+	require.Contains(t, idx.nav["second"], `href="/article/first" class="series-prev"`)
+	require.Contains(t, idx.nav["second"], "part 2 of 3")
+	require.NotContains(t, idx.nav["third"], "series-next")
+}
+
+func TestSeriesSlugsUnique(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	for name, body := range map[string]string{
+		"k8s/intro.md":    "# Kubernetes Intro",
+		"go/intro.md":     "# Go Intro",
+		"root.md":         "# Root",
+		"a-b/one.md":      "# One",
+		"a/b/two.md":      "# Two",
+		"a/b/_index.md":   "---\ntitle: Clash\n---\n",
+		"custom/three.md": "---\nslug: three\n---\n# Three",
+	} {
+		p := filepath.Join(cfg.ContentDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(body), 0o644))
+	}
+
+	bm := NewBlogManager(cfg)
+	require.NoError(t, bm.updateContent())
+
+	for slug, title := range map[string]string{
+		"k8s-intro": "Kubernetes Intro", "go-intro": "Go Intro", "root": "Root", "a-b-one": "One", "three": "Three",
+	} {
+		arti, found := bm.GetArticle(slug)
+		require.True(t, found, slug)
+		require.Equal(t, title, arti.Title)
+	}
+
+	var dups []string
+	for _, issue := range bm.LastUpdateReport().Issues {
+		require.Equal(t, checkDuplicateSlug, issue.Check)
+		require.Contains(t, issue.Message, `series "a-b" already used by a-b/`)
+		dups = append(dups, issue.Source)
+	}
+	require.ElementsMatch(t, []string{"a/b/_index.md", "a/b/two.md"}, dups)
+}
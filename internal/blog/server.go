@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"net/url"
@@ -24,16 +25,24 @@ import (
 )
 
 type Server struct {
-	bm           *BlogManager
-	tracer       trace.Tracer
-	srv          *http.Server
-	lts          *LocalTelemetryStorage
-	startTime    time.Time
-	articleViews metric.Int64Counter
-	badReq       metric.Int64Counter
-	roboVisit    metric.Int64Counter
-	errChan      chan error
-	sigChan      chan os.Signal
+	bm             *BlogManager
+	previews       map[string]*BlogManager // by branch, empty unless preview branches are configured
+	tracer         trace.Tracer
+	srv            *http.Server
+	lts            *LocalTelemetryStorage
+	startTime      time.Time
+	articleViews   metric.Int64Counter
+	badReq         metric.Int64Counter
+	roboVisit      metric.Int64Counter
+	searchDur      metric.Float64Histogram
+	webhookAccept  metric.Int64Counter
+	webhookReject  metric.Int64Counter
+	webhookLimiter *rateLimiter
+	adminLimiter   *rateLimiter
+	highlightCSS   []byte
+	csp            string // Content-Security-Policy header of every response
+	errChan        chan error
+	sigChan        chan os.Signal
 }
 
 func NewServer(bm *BlogManager, ls *LocalTelemetryStorage) *Server {
@@ -61,19 +70,63 @@ func NewServer(bm *BlogManager, ls *LocalTelemetryStorage) *Server {
 		return nil
 	}
 
+	searchDur, err := meter.Float64Histogram(
+		"search.duration",
+		metric.WithDescription("time taken to answer a search query"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil
+	}
+
+	webhookAccept, err := meter.Int64Counter(
+		"webhook.accepted", metric.WithDescription("number of webhook deliveries that triggered a content update"),
+	)
+	if err != nil {
+		return nil
+	}
+
+	webhookReject, err := meter.Int64Counter(
+		"webhook.rejected", metric.WithDescription("number of webhook deliveries rejected or ignored"),
+	)
+	if err != nil {
+		return nil
+	}
+
+	highlightCSS, err := HighlightCSS(bm.Config.HighlightStyle)
+	if err != nil {
+		return nil
+	}
+
 	return &Server{
-		bm:           bm,
-		tracer:       otel.Tracer("jake-blog"),
-		startTime:    time.Now(),
-		articleViews: articleViews,
-		badReq:       badRequest,
-		roboVisit:    robo,
-		errChan:      make(chan error, 1),
-		sigChan:      make(chan os.Signal, 1),
-		lts:          ls,
+		bm:             bm,
+		tracer:         otel.Tracer("jake-blog"),
+		startTime:      time.Now(),
+		articleViews:   articleViews,
+		badReq:         badRequest,
+		roboVisit:      robo,
+		searchDur:      searchDur,
+		webhookAccept:  webhookAccept,
+		webhookReject:  webhookReject,
+		webhookLimiter: newRateLimiter(webhookRateBurst, webhookRateRefill),
+		adminLimiter:   newRateLimiter(adminRateBurst, adminRateRefill),
+		highlightCSS:   highlightCSS,
+		csp:            contentSecurityPolicy(bm.Config),
+		errChan:        make(chan error, 1),
+		sigChan:        make(chan os.Signal, 1),
+		lts:            ls,
 	}
 }
 
+// contentSecurityPolicy allows images from the image bucket when pages link to it
+func contentSecurityPolicy(cfg *Config) string {
+	imgSrc := "'self'"
+	if origin, err := imageBucketOrigin(imageBucketURL(cfg)); err == nil {
+		imgSrc += " " + origin
+	}
+	return `default-src 'self'; script-src 'self'; script-src-elem 'self'; style-src 'self' ; img-src ` + imgSrc + `; connect-src 'self'`
+}
+
 func (s *Server) Start(ctx context.Context) error {
 	s.srv = &http.Server{
 		Handler:      s.SetupRoutes(),
@@ -81,6 +134,8 @@ func (s *Server) Start(ctx context.Context) error {
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
+	// reload streams stay open until the server stops
+	s.srv.RegisterOnShutdown(s.bm.reloads.close)
 
 	serverLogger.Info().Msgf("https enabled: %t", s.bm.Config.HTTPSOn)
 
@@ -149,13 +204,16 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.Handle("/", s.wrapHandler(
-		http.FileServer(http.Dir("/web")),
+		http.FileServer(http.Dir(s.bm.Config.WebDir)),
 		"static file server",
 	))
+	mux.Handle("/{$}", s.wrapHandler(
+		http.HandlerFunc(s.Home),
+		"home page",
+	))
 
-	mux.Handle("/article/images/", s.wrapHandler(
-		http.StripPrefix("/article/images/",
-			http.FileServer(http.Dir(s.bm.Config.ContentDir+"/images"))),
+	mux.Handle(imageURLPrefix, s.wrapHandler(
+		http.HandlerFunc(s.ImageHandler),
 		"image file server",
 	))
 
@@ -173,6 +231,33 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		http.HandlerFunc(s.RssFeedHandler),
 		"RSS Feed Handler",
 	))
+	mux.Handle("/feed/atom.xml", s.wrapHandler(
+		http.HandlerFunc(s.AtomFeedHandler),
+		"Atom Feed Handler",
+	))
+	mux.Handle("/feed/feed.json", s.wrapHandler(
+		http.HandlerFunc(s.JSONFeedHandler),
+		"JSON Feed Handler",
+	))
+	mux.Handle("/feed/tags/", s.wrapHandler(
+		http.HandlerFunc(s.TagRssFeedHandler),
+		"tag RSS Feed Handler",
+	))
+
+	mux.Handle("/series/", s.wrapHandler(
+		http.HandlerFunc(s.SeriesHandler),
+		"series handler",
+	))
+
+	mux.Handle("/search", s.wrapHandler(
+		http.HandlerFunc(s.SearchHandler),
+		"search handler",
+	))
+
+	mux.Handle("/tags/", s.wrapHandler(
+		http.HandlerFunc(s.Tags),
+		"tag handler",
+	))
 
 	mux.Handle("/robots.txt", s.wrapHandler(
 		http.HandlerFunc(s.RobotsHandler),
@@ -184,6 +269,51 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		"sitemap handler",
 	))
 
+	mux.Handle(highlightCSSPath, s.wrapHandler(
+		http.HandlerFunc(s.HighlightCSSHandler),
+		"highlight css handler",
+	))
+
+	if s.bm.Config.DevMode {
+		mux.Handle(devReloadPath, s.wrapHandler(
+			http.HandlerFunc(s.DevReload),
+			"dev reload",
+		))
+		mux.Handle(devReloadScriptPath, s.wrapHandler(
+			http.HandlerFunc(s.DevReloadScript),
+			"dev reload script",
+		))
+	}
+
+	if len(s.previews) > 0 {
+		mux.Handle("/preview/", s.wrapHandler(
+			http.HandlerFunc(s.PreviewHandler),
+			"preview",
+		))
+	}
+
+	if s.bm.Config.WebhookSecret != "" {
+		mux.Handle("/hooks/content", s.wrapWebhook(
+			http.HandlerFunc(s.ContentWebhook),
+			"content webhook",
+		))
+	}
+
+	if s.bm.Config.AdminToken != "" {
+		mux.Handle("/admin/content/snapshots", s.wrapAdmin(
+			http.HandlerFunc(s.AdminSnapshots), http.MethodGet, "admin snapshots",
+		))
+		mux.Handle("/admin/content/report", s.wrapAdmin(
+			http.HandlerFunc(s.AdminReport), http.MethodGet, "admin report",
+		))
+		mux.Handle("/admin/content/rollback", s.wrapAdmin(
+			http.HandlerFunc(s.AdminRollback), http.MethodPost, "admin rollback",
+		))
+		mux.Handle("/admin/content/unpin", s.wrapAdmin(
+			http.HandlerFunc(s.AdminUnpin), http.MethodPost, "admin unpin",
+		))
+	}
+
 	mux.HandleFunc("/telemetry/trace", s.LastTrace)
 	mux.HandleFunc("/telemetry/metric", s.MetricSnippet)
 	mux.HandleFunc("/telemetry/cost", s.CostSnippet)
@@ -230,7 +360,7 @@ func (s *Server) wrapHandler(h http.Handler, name string) http.Handler {
 		}
 
 		// add csp headers
-		w.Header().Set("Content-Security-Policy", `default-src 'self'; script-src 'self'; script-src-elem 'self'; style-src 'self' ; img-src 'self' https://jakeblog-blog-image-cache.s3.us-east-1.amazonaws.com; connect-src 'self'`)
+		w.Header().Set("Content-Security-Policy", s.csp)
 
 		h.ServeHTTP(w, r)
 	})
@@ -243,6 +373,23 @@ func (s *Server) wrapHandler(h http.Handler, name string) http.Handler {
 	)
 }
 
+// Home serves the home page layout of the served theme
+func (s *Server) Home(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "HomeHandler.Process")
+	defer span.End()
+
+	page := s.bm.GetHomePage()
+	if s.bm.Config.DevMode {
+		page = withDevReload(page)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write(page)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "failed to write home page"))
+	}
+}
+
 func (s *Server) ArticleList(w http.ResponseWriter, r *http.Request) {
 	_, span := s.tracer.Start(r.Context(), "ArticleListHandler.Process")
 	defer span.End()
@@ -283,7 +430,7 @@ func (s *Server) Article(w http.ResponseWriter, r *http.Request) {
 	article, exists := s.bm.GetArticle(articleName)
 	if !exists {
 		span.SetAttributes(attribute.String("error", "article not found"))
-		http.NotFound(w, r)
+		s.errorPage(w, http.StatusNotFound, "page not found")
 		return
 	}
 
@@ -297,14 +444,28 @@ func (s *Server) Article(w http.ResponseWriter, r *http.Request) {
 		1,
 	)
 
+	page := article.Content
+	if s.bm.Config.DevMode {
+		page = withDevReload(page)
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, err = w.Write(article.Content) // #nosec G705 -- content is from our own git repo, not user input
+	_, err = w.Write(page) // #nosec G705 -- content is from our own git repo, not user input
 	if err != nil {
 		serverLogger.Error().Msgf("failed to send article to client: %v", err)
 		span.SetAttributes(attribute.String("error", "write failed"))
 	}
 }
 
+// errorPage answers with the error layout of the served theme
+func (s *Server) errorPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(s.bm.Theme().ErrorPage(status, message)); err != nil {
+		serverLogger.Error().Msgf("failed to send error page to client: %v", err)
+	}
+}
+
 func (s *Server) LastTrace(w http.ResponseWriter, r *http.Request) {
 	jsonStr, err := s.lts.GetLastSpanJSON()
 	if err != nil {
@@ -421,6 +582,58 @@ func (s *Server) writeMetricSnippet(w io.Writer) error {
 		}
 	}
 
+	ew.str("<p>blog.webhook.accepted: ")
+	ew.int64(s.lts.webhookAccepted.Load())
+	ew.str("</p>")
+
+	ew.str("<p>blog.webhook.rejected: ")
+	ew.int64(s.lts.webhookRejected.Load())
+	ew.str("</p>")
+
+	report := s.bm.LastUpdateReport()
+	ew.str("<p>blog.content.validation.issues: ")
+	ew.int64(int64(len(report.Issues)))
+	ew.str("</p>")
+
+	ew.str("<p>blog.content.stale: ")
+	ew.int64(int64(len(report.Stale)))
+	ew.str("</p>")
+
+	ew.str("<p>blog.content.update.rejected: ")
+	if report.Rejected {
+		ew.str("true")
+	} else {
+		ew.str("false")
+	}
+	ew.str("</p>")
+
+	ew.str("<p>blog.content.pinned: ")
+	if pinned := s.bm.PinnedCommit(); pinned != "" {
+		ew.str(pinned)
+	} else {
+		ew.str("none")
+	}
+	ew.str("</p>")
+
+	if s.bm.Config.PollInterval > 0 {
+		poll := s.bm.GetPollStatus()
+		ew.str("<p>blog.poll.last_check: ")
+		if poll.LastCheck.IsZero() {
+			ew.str("never")
+		} else {
+			ew.str(poll.LastCheck.UTC().Format(time.RFC3339))
+		}
+		ew.str("</p>")
+
+		ew.str("<p>blog.poll.remote_hash: ")
+		ew.str(poll.RemoteHash.String())
+		ew.str("</p>")
+
+		ew.str("<p>blog.poll.failures: ")
+		ew.int64(int64(poll.Failures))
+		ew.str("</p>")
+	}
+
 	ew.str("<p>blog.requests.robots: ")
 	ew.int64(s.lts.roboticVisitors.Load())
 	ew.str("</p>")
@@ -495,6 +708,136 @@ func (s *Server) RssFeedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) SeriesHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "SeriesHandler.Process")
+	defer span.End()
+
+	unescaped, err := url.QueryUnescape(r.URL.Path)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "invalid url encoding"))
+		http.Error(w, "invalid url encoding", http.StatusBadRequest)
+		return
+	}
+
+	seriesName := path.Base(unescaped)
+	span.SetAttributes(attribute.String("series.name", seriesName))
+
+	page, exists := s.bm.GetSeriesPage(seriesName)
+	if !exists {
+		span.SetAttributes(attribute.String("error", "series not found"))
+		s.errorPage(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write(page)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "write failed"))
+	}
+}
+
+// SearchHandler serves an html fragment of ranked results for ?q=
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "SearchHandler.Process")
+	defer span.End()
+	start := time.Now()
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) > searchMaxQueryLen {
+		s.reqBlockedInstrument("QUERY_LENGTH", ctx)
+		http.Error(w, "query too long", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("search.query", query))
+
+	var b strings.Builder
+	if query != "" {
+		results := s.bm.Search(query)
+		span.SetAttributes(attribute.Int("search.results", len(results)))
+		if len(results) == 0 {
+			b.WriteString(`<li class="search-empty">No posts match "`)
+			b.WriteString(html.EscapeString(query))
+			b.WriteString(`"</li>`)
+		}
+		for _, res := range results {
+			b.WriteString(`<li><a href="`)
+			b.WriteString(html.EscapeString(res.URL))
+			b.WriteString(`">`)
+			b.WriteString(html.EscapeString(res.Title))
+			b.WriteString(`</a><p class="snippet">`)
+			b.WriteString(res.Snippet)
+			b.WriteString(`</p></li>`)
+		}
+	}
+
+	s.searchDur.Record(ctx, float64(time.Since(start).Microseconds())/1000)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write([]byte(b.String()))
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "failed to write search results"))
+	}
+}
+
+// Tags serves the tag list on /tags/ and the article list for one tag on /tags/{tag}
+func (s *Server) Tags(w http.ResponseWriter, r *http.Request) {
+	_, span := s.tracer.Start(r.Context(), "TagHandler.Process")
+	defer span.End()
+
+	// r.URL.Path is already decoded, decoding it again breaks tags like "100%"
+	tag := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+
+	var snippet []byte
+	if tag == "" {
+		snippet = s.bm.GetTagList()
+	} else {
+		span.SetAttributes(attribute.String("tag.name", tag))
+		list, exists := s.bm.GetTagArticleList(tag)
+		if !exists {
+			span.SetAttributes(attribute.String("error", "tag not found"))
+			http.NotFound(w, r)
+			return
+		}
+		snippet = list
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write(snippet)
+	if err != nil {
+		span.SetAttributes(attribute.String("error", "failed to write tag snippet"))
+	}
+}
+
+func (s *Server) AtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/atom+xml")
+	_, err := w.Write(s.bm.GetAtomFeed())
+	if err != nil {
+		serverLogger.Error().Msgf("failed to send atom feed to client: %v", err)
+	}
+}
+
+func (s *Server) JSONFeedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/feed+json")
+	_, err := w.Write(s.bm.GetJSONFeed())
+	if err != nil {
+		serverLogger.Error().Msgf("failed to send json feed to client: %v", err)
+	}
+}
+
+func (s *Server) TagRssFeedHandler(w http.ResponseWriter, r *http.Request) {
+	feedContent, exists := s.bm.GetTagRssFeed(strings.Trim(strings.TrimPrefix(r.URL.Path, "/feed/tags/"), "/"))
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	_, err := w.Write(feedContent)
+	if err != nil {
+		serverLogger.Error().Msgf("failed to send tag rss feed to client: %v", err)
+	}
+}
+
 func (s *Server) SiteMapHandler(w http.ResponseWriter, r *http.Request) {
 	smap := s.bm.GetSiteMap()
 	_, err := w.Write(smap)
@@ -503,12 +846,27 @@ func (s *Server) SiteMapHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HighlightCSSHandler serves the code highlighting stylesheet generated at startup
+func (s *Server) HighlightCSSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	_, err := w.Write(s.highlightCSS)
+	if err != nil {
+		serverLogger.Error().Msgf("failed to write highlight css: %v", err)
+	}
+}
+
+const robotsTxt = "User-agent: *\n" +
+	"Disallow: /content\n" +
+	"Disallow: /tags/\n" +
+	"Disallow: /search\n" +
+	"Disallow: /hooks/\n" +
+	"Disallow: /preview/\n" +
+	"Disallow: /admin/\n" +
+	"Disallow: /telemetry/\n\n" +
+	"Sitemap: https://jake-henning.com/sitemap.xml"
+
 func (s *Server) RobotsHandler(w http.ResponseWriter, r *http.Request) {
-	smap := "User-agent: *\n" +
-		"Disallow: /content\n" +
-		"Disallow: /telemetry/\n\n" +
-		"Sitemap: https://jake-henning.com/sitemap.xml"
-	_, err := w.Write([]byte(smap))
+	_, err := w.Write([]byte(robotsTxt))
 	if err != nil {
 		serverLogger.Error().Msgf("failed to write robots.txt: %v", err)
 	}
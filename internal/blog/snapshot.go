@@ -0,0 +1,195 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	errNoSnapshot = errors.New("no matching content snapshot")
+	// LocalOnly updates all have the zero head so each replaces the last snapshot
+	errLocalOnlyRollback = errors.New("rollback needs content commits and is not available with local only content")
+)
+
+// contentSnapshot is everything one content update built. the rendered articles
+// are kept with the index so the scheduler can keep rebuilding a pinned snapshot
+type contentSnapshot struct {
+	head     plumbing.Hash
+	built    time.Time
+	rendered map[string]Article
+	series   map[string]Series
+	theme    *Theme
+	images   imageSet
+	index    contentIndex
+}
+
+// SnapshotInfo describes a retained snapshot for the admin api
+type SnapshotInfo struct {
+	Commit   string    `json:"commit"`
+	Built    time.Time `json:"built"`
+	Articles int       `json:"articles"`
+	Serving  bool      `json:"serving"`
+	Pinned   bool      `json:"pinned"`
+}
+
+// recordSnapshot keeps snap as the newest snapshot. rebuilding the same commit
+// replaces its old snapshot. caller holds buildMutex
+func (bm *BlogManager) recordSnapshot(snap contentSnapshot) {
+	snapshots := make([]contentSnapshot, 0, bm.Config.SnapshotHistory)
+	snapshots = append(snapshots, snap)
+	for _, old := range bm.snapshots {
+		if len(snapshots) == bm.Config.SnapshotHistory {
+			break
+		}
+		if old.head != snap.head {
+			snapshots = append(snapshots, old)
+		}
+	}
+	bm.snapshots = snapshots
+}
+
+// servingHead is the commit of the snapshot being served. caller holds buildMutex
+func (bm *BlogManager) servingHead() plumbing.Hash {
+	if bm.pinned != nil {
+		return bm.pinned.head
+	}
+	if len(bm.snapshots) > 0 {
+		return bm.snapshots[0].head
+	}
+	return plumbing.ZeroHash
+}
+
+func (bm *BlogManager) Snapshots() []SnapshotInfo {
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+
+	serving := bm.servingHead()
+	infos := make([]SnapshotInfo, 0, len(bm.snapshots))
+	for _, snap := range bm.snapshots {
+		infos = append(infos, SnapshotInfo{
+			Commit:   snap.head.String(),
+			Built:    snap.built,
+			Articles: len(snap.index.articles),
+			Serving:  snap.head == serving,
+			Pinned:   bm.pinned != nil && snap.head == bm.pinned.head,
+		})
+	}
+	return infos
+}
+
+// PinnedCommit is the commit content is pinned to, empty when following the branch
+func (bm *BlogManager) PinnedCommit() string {
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+	if bm.pinned == nil {
+		return ""
+	}
+	return bm.pinned.head.String()
+}
+
+// Rollback swaps in the snapshot for commit and pins it so later updates are built
+// but not served. an empty commit means the snapshot before the one being served.
+// commit may be abbreviated. the git worktree is not touched
+func (bm *BlogManager) Rollback(commit string) (string, error) {
+	if bm.Config.LocalOnly {
+		return "", errLocalOnlyRollback
+	}
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+
+	target := -1
+	if commit == "" {
+		serving := bm.servingHead()
+		for i, snap := range bm.snapshots {
+			if snap.head == serving && i+1 < len(bm.snapshots) {
+				target = i + 1
+				break
+			}
+		}
+	} else {
+		commit = strings.ToLower(commit)
+		for i, snap := range bm.snapshots {
+			if strings.HasPrefix(snap.head.String(), commit) {
+				if target >= 0 {
+					return "", fmt.Errorf("commit %s is ambiguous", commit)
+				}
+				target = i
+			}
+		}
+	}
+	if target < 0 {
+		return "", errNoSnapshot
+	}
+
+	snap := bm.snapshots[target]
+	bm.pinned = &snap
+	bm.rendered = snap.rendered
+	bm.series = snap.series
+	bm.theme = snap.theme
+	bm.swapContentIndex(snap.index)
+	bm.serveImages(snap.images)
+	bm.rescheduleArticles()
+
+	managerLogger.Warn().Msgf("content rolled back and pinned to %s", snap.head)
+	return snap.head.String(), nil
+}
+
+// Unpin goes back to serving the newest snapshot and following the content branch
+func (bm *BlogManager) Unpin() error {
+	bm.buildMutex.Lock()
+	defer bm.buildMutex.Unlock()
+
+	if bm.pinned == nil {
+		return fmt.Errorf("content is not pinned")
+	}
+	bm.pinned = nil
+	if len(bm.snapshots) == 0 {
+		return nil
+	}
+
+	latest := bm.snapshots[0]
+	bm.rendered = latest.rendered
+	bm.series = latest.series
+	bm.theme = latest.theme
+	// rebuilt rather than reusing latest.index since scheduled articles may have gone live
+	bm.swapContentIndex(buildContentIndex(context.Background(), bm.Config, latest.theme, latest.rendered, latest.series, time.Now()))
+	bm.serveImages(latest.images)
+	bm.rescheduleArticles()
+
+	managerLogger.Warn().Msgf("content unpinned: serving %s", latest.head)
+	return nil
+}
+
+// SIGUSR1 rolls back to the previous snapshot, SIGUSR2 unpins
+func (bm *BlogManager) listenForRollback(ctx context.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigs:
+				if sig == syscall.SIGUSR2 {
+					if err := bm.Unpin(); err != nil {
+						managerLogger.Error().Msgf("unpin failed: %v", err)
+					}
+					continue
+				}
+				if _, err := bm.Rollback(""); err != nil {
+					managerLogger.Error().Msgf("rollback failed: %v", err)
+				}
+			}
+		}
+	}()
+}
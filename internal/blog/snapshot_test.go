@@ -0,0 +1,149 @@
+package blog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotManager records one snapshot per commit, each serving a single article named after it
+func snapshotManager(commits ...string) *BlogManager {
+	cfg := DefaultConfig()
+	cfg.SnapshotHistory = 3
+	bm := NewBlogManager(cfg)
+
+	now := time.Now()
+	for _, commit := range commits {
+		slug := "post-" + commit[:4]
+		rendered := map[string]Article{
+			slug: {Title: slug, Slug: slug, URL: "/article/" + slug, Date: now.Add(-time.Hour), Content: []byte(commit)},
+		}
+		idx := buildContentIndex(context.Background(), cfg, nil, rendered, nil, now)
+		bm.recordSnapshot(contentSnapshot{head: plumbing.NewHash(commit), built: now, rendered: rendered, index: idx})
+		bm.rendered = rendered
+		bm.swapContentIndex(idx)
+	}
+	return bm
+}
+
+const (
+	commitA = "aaaa000000000000000000000000000000000000"
+	commitB = "bbbb000000000000000000000000000000000000"
+	commitC = "cccc000000000000000000000000000000000000"
+	commitD = "dddd000000000000000000000000000000000000"
+)
+
+func TestRecordSnapshotHistory(t *testing.T) {
+	bm := snapshotManager(commitA, commitB, commitC, commitD, commitC)
+
+	var commits []string
+	for _, info := range bm.Snapshots() {
+		commits = append(commits, info.Commit)
+	}
+	// capped at SnapshotHistory and a rebuilt commit moves to the front
+	require.Equal(t, []string{commitC, commitD, commitB}, commits)
+	require.True(t, bm.Snapshots()[0].Serving)
+}
+
+func TestRollbackAndUnpin(t *testing.T) {
+	bm := snapshotManager(commitA, commitB, commitC)
+	_, live := bm.GetArticle("post-cccc")
+	require.True(t, live)
+
+	pinned, err := bm.Rollback("")
+	require.NoError(t, err)
+	require.Equal(t, commitB, pinned)
+	require.Equal(t, commitB, bm.PinnedCommit())
+	_, live = bm.GetArticle("post-cccc")
+	require.False(t, live)
+	_, live = bm.GetArticle("post-bbbb")
+	require.True(t, live)
+
+	// stepping back again goes one further from the pinned snapshot
+	pinned, err = bm.Rollback("")
+	require.NoError(t, err)
+	require.Equal(t, commitA, pinned)
+
+	_, err = bm.Rollback("")
+	require.ErrorIs(t, err, errNoSnapshot)
+
+	pinned, err = bm.Rollback("bbbb")
+	require.NoError(t, err)
+	require.Equal(t, commitB, pinned)
+
+	// scheduled rebuilds keep serving the pinned content
+	bm.rebuildIndexes()
+	_, live = bm.GetArticle("post-bbbb")
+	require.True(t, live)
+
+	require.NoError(t, bm.Unpin())
+	require.Empty(t, bm.PinnedCommit())
+	_, live = bm.GetArticle("post-cccc")
+	require.True(t, live)
+	require.Error(t, bm.Unpin())
+}
+
+func TestRollbackLocalOnly(t *testing.T) {
+	bm := snapshotManager(commitA)
+	bm.Config.LocalOnly = true
+	bm.Config.AdminToken = "0123456789abcdef0123456789abcdef"
+
+	_, err := bm.Rollback("")
+	require.ErrorIs(t, err, errLocalOnlyRollback)
+	require.Empty(t, bm.PinnedCommit())
+
+	s := NewServer(bm, NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	req := httptest.NewRequest(http.MethodPost, "/admin/content/rollback", nil)
+	req.Header.Set("Authorization", "Bearer "+bm.Config.AdminToken)
+	rec := httptest.NewRecorder()
+	s.SetupRoutes().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "local only")
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef"
+	bm := snapshotManager(commitA, commitB)
+	bm.Config.AdminToken = token
+	s := NewServer(bm, NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	handler := s.SetupRoutes()
+
+	do := func(method, target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/content/snapshots", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/content/rollback", "wrong").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/admin/content/rollback", token).Code)
+
+	rec := do(http.MethodGet, "/admin/content/snapshots", token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var infos []SnapshotInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &infos))
+	require.Len(t, infos, 2)
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/content/rollback?commit=ffff", token).Code)
+
+	rec = do(http.MethodPost, "/admin/content/rollback?commit=aaaa", token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, strings.Contains(rec.Body.String(), commitA))
+	require.Equal(t, commitA, bm.PinnedCommit())
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodPost, "/admin/content/unpin", token).Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/admin/content/unpin", token).Code)
+}
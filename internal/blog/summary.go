@@ -0,0 +1,57 @@
+package blog
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	readingWPM   = 200 // average adult silent reading speed
+	summaryWidth = 160 // bytes of text in a summary taken from the body
+)
+
+var paragraphRe = regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+
+// countWords counts the words in the readable text of rendered markdown. runs of
+// punctuation like heading anchors are not words
+func countWords(body []byte) int {
+	words := 0
+	for _, field := range strings.Fields(stripHTML(string(body))) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// readingMinutes rounds up so every article is at least a minute read
+func readingMinutes(words int) int {
+	return max(1, (words+readingWPM-1)/readingWPM)
+}
+
+// firstParagraph is the text of the first paragraph with any text, cut at a word
+// boundary when it is long
+func firstParagraph(body []byte) string {
+	for _, match := range paragraphRe.FindAllSubmatch(body, -1) {
+		if text := stripHTML(string(match[1])); text != "" {
+			return truncateText(text, summaryWidth)
+		}
+	}
+	return ""
+}
+
+func truncateText(text string, width int) string {
+	if len(text) <= width {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:width], ' ')
+	if cut <= 0 {
+		cut = width
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+	}
+	return text[:cut] + "…"
+}
@@ -0,0 +1,55 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountWords(t *testing.T) {
+	body := []byte(`<h2 id="intro">Intro <a class="heading-anchor" href="#intro">#</a></h2>` +
+		"\n<p>Go&rsquo;s tooling &mdash; <code>go test</code> runs 3 ways.</p>")
+	require.Equal(t, 8, countWords(body))
+	require.Zero(t, countWords(nil))
+}
+
+func TestReadingMinutes(t *testing.T) {
+	tests := []struct {
+		words int
+		want  int
+	}{
+		{0, 1},
+		{1, 1},
+		{readingWPM, 1},
+		{readingWPM + 1, 2},
+		{10 * readingWPM, 10},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, readingMinutes(tt.words), tt.words)
+	}
+}
+
+func TestFirstParagraph(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"first paragraph", "<h1>Title</h1>\n<p>The <em>first</em> one.</p>\n<p>second</p>", "The first one."},
+		{"skips image only paragraphs", `<p><img src="a.png" alt="a"></p><p>text &amp; more</p>`, "text & more"},
+		{"multi line", "<p>one\ntwo</p>", "one two"},
+		{"no paragraph", "<pre><code>code</code></pre>", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, firstParagraph([]byte(tt.body)))
+		})
+	}
+
+	long := firstParagraph([]byte("<p>" + strings.Repeat("word ", 100) + "</p>"))
+	require.True(t, strings.HasSuffix(long, "word…"))
+	require.LessOrEqual(t, len(long), summaryWidth+len("…"))
+}
@@ -0,0 +1,84 @@
+package blog
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type tagIndex struct {
+	list         []byte
+	articleLists map[string][]byte
+	feeds        map[string][]byte
+}
+
+// lower case and hyphenate so "Site Reliability" and "site-reliability" are the same tag
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t := normalizeTag(tag)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized
+}
+
+// buildTagIndex expects articles sorted newest first so the per tag lists and feeds are too
+func buildTagIndex(theme *Theme, articles []Article, feedItems int) tagIndex {
+	tagged := make(map[string][]Article)
+	for i := range articles {
+		for _, tag := range articles[i].Tags {
+			tagged[tag] = append(tagged[tag], articles[i])
+		}
+	}
+
+	names := make([]string, 0, len(tagged))
+	for tag := range tagged {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
+	idx := tagIndex{
+		articleLists: make(map[string][]byte, len(names)),
+		feeds:        make(map[string][]byte, len(names)),
+	}
+
+	var tagLinks []string
+	for _, tag := range names {
+		escapedPath := url.PathEscape(tag)
+		tagLinks = append(tagLinks, fmt.Sprintf(
+			`<li><a href="/tags/%s" hx-get="/tags/%s" hx-target="#article-list" hx-swap="innerHTML">%s</a> <span class="count">(%d)</span></li>`,
+			escapedPath, escapedPath, html.EscapeString(tag), len(tagged[tag])))
+
+		list, err := theme.TagFragment(tag, tagged[tag])
+		if err != nil {
+			managerLogger.Error().Str("tag", tag).Msgf("theme failed, using the default tag layout: %v", err)
+			list, _ = defaultTheme.TagFragment(tag, tagged[tag])
+		}
+		idx.articleLists[tag] = list
+
+		feed, err := buildRSSFeed(
+			fmt.Sprintf("%s - %s", siteTitle, tag),
+			fmt.Sprintf("%s/feed/tags/%s", siteURL, escapedPath),
+			tagged[tag][:min(len(tagged[tag]), feedItems)],
+		)
+		if err != nil {
+			managerLogger.Error().Str("tag", tag).Msgf("failed to build tag rss feed: %v", err)
+			continue
+		}
+		idx.feeds[tag] = feed
+	}
+	idx.list = []byte(strings.Join(tagLinks, ""))
+
+	return idx
+}
@@ -0,0 +1,90 @@
+package blog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"go":                     "go",
+		"Go":                     "go",
+		"Site Reliability":       "site-reliability",
+		"  site \t reliability ": "site-reliability",
+		"site-reliability":       "site-reliability",
+		"100%":                   "100%",
+		"":                       "",
+		"   ":                    "",
+	}
+	for in, want := range tests {
+		require.Equal(t, want, normalizeTag(in), in)
+	}
+
+	require.Equal(t, []string{"go", "site-reliability"}, normalizeTags([]string{"Go", "", "site reliability", "go", "Site-Reliability"}))
+}
+
+var taggedArticles = []Article{
+	{Title: "Newest", Slug: "newest", URL: "/article/newest", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"go", "100%"}},
+	{Title: "Middle", Slug: "middle", URL: "/article/middle", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"go"}},
+	{Title: "Oldest", Slug: "oldest", URL: "/article/oldest", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Tags: []string{"go", "sre"}},
+	{Title: "Untagged", Slug: "untagged", URL: "/article/untagged", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+}
+
+func TestBuildTagIndex(t *testing.T) {
+	idx := buildTagIndex(defaultTheme, taggedArticles, 2)
+
+	list := string(idx.list)
+	require.Less(t, strings.Index(list, ">100%<"), strings.Index(list, ">go<"), "tags are listed in order")
+	require.Less(t, strings.Index(list, ">go<"), strings.Index(list, ">sre<"))
+	require.Contains(t, list, `href="/tags/go"`)
+	require.Contains(t, list, `<span class="count">(3)</span>`)
+	require.Contains(t, list, `href="/tags/100%25"`, "tags are escaped in links")
+
+	require.Len(t, idx.articleLists, 3)
+	goList := string(idx.articleLists["go"])
+	require.Less(t, strings.Index(goList, "/article/newest"), strings.Index(goList, "/article/middle"))
+	require.Less(t, strings.Index(goList, "/article/middle"), strings.Index(goList, "/article/oldest"))
+	require.NotContains(t, goList, "/article/untagged")
+
+	require.Len(t, idx.feeds, 3)
+	feed := string(idx.feeds["go"])
+	require.Contains(t, feed, "/article/newest")
+	require.Contains(t, feed, "/article/middle")
+	require.NotContains(t, feed, "/article/oldest", "feeds are limited to feedItems")
+	require.Contains(t, string(idx.feeds["100%"]), "/feed/tags/100%25")
+}
+
+func TestTagHandlers(t *testing.T) {
+	bm := NewBlogManager(DefaultConfig())
+	idx := buildTagIndex(defaultTheme, taggedArticles, 10)
+	bm.TagList, bm.TagArticleLists, bm.TagRSSFeeds = idx.list, idx.articleLists, idx.feeds
+	s := NewServer(bm, nil)
+	require.NotNil(t, s)
+	handler := s.SetupRoutes()
+
+	tests := []struct {
+		target string
+		want   int
+		body   string
+	}{
+		{"/tags/", http.StatusOK, `href="/tags/sre"`},
+		{"/tags/go", http.StatusOK, "/article/middle"},
+		{"/tags/Go/", http.StatusOK, "/article/middle"},
+		{"/tags/100%25", http.StatusOK, "/article/newest"},
+		{"/tags/missing", http.StatusNotFound, ""},
+		{"/feed/tags/100%25", http.StatusOK, "/article/newest"},
+		{"/feed/tags/sre", http.StatusOK, "/article/oldest"},
+		{"/feed/tags/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		require.Equal(t, tt.want, rec.Code, tt.target)
+		require.Contains(t, rec.Body.String(), tt.body, tt.target)
+	}
+}
@@ -36,6 +36,8 @@ type LocalTelemetryStorage struct {
 	stackAlloc          atomic.Int64
 	costUpdateSuccess   atomic.Int64
 	costUpdateFailure   atomic.Int64
+	webhookAccepted     atomic.Int64
+	webhookRejected     atomic.Int64
 
 	spanMu       sync.RWMutex
 	costMu       sync.RWMutex
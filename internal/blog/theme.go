@@ -0,0 +1,190 @@
+package blog
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// default layouts, each can be replaced by a file of the same name in the
+// theme directory of the content repo
+//
+//go:embed theme/*.html
+var defaultThemeFS embed.FS
+
+const themeDir = "theme"
+
+var (
+	// full pages define "title", "head" and "content" blocks for the base layout.
+	// the home page also replaces its "styles", "header" and "scripts" blocks
+	themePages = []string{"home", "article", "series", "error"}
+	// fragments are swapped into index.html by htmx and stand alone
+	themeFragments = []string{"list", "tag"}
+)
+
+// SiteMeta is the site wide metadata every template gets
+type SiteMeta struct {
+	URL         string
+	Title       string
+	Description string
+	Author      string
+}
+
+var site = SiteMeta{URL: siteURL, Title: siteTitle, Description: siteDescription, Author: siteAuthor}
+
+// PageData is what every layout is executed with. fields that do not apply to a
+// layout are left zero
+type PageData struct {
+	Site     SiteMeta
+	Article  *Article
+	Articles []Article
+	Series   *Series
+	Tag      string
+	Nav      template.HTML // series navigation for an article
+	Status   int
+	Message  string
+}
+
+type Theme struct {
+	pages     map[string]*template.Template
+	fragments map[string]*template.Template
+}
+
+// Theme is the theme of the content being served
+func (bm *BlogManager) Theme() *Theme {
+	bm.articleMutex.RLock()
+	defer bm.articleMutex.RUnlock()
+	if bm.servedTheme == nil {
+		return defaultTheme
+	}
+	return bm.servedTheme
+}
+
+var defaultTheme = func() *Theme {
+	theme, err := parseTheme(func(name string) ([]byte, error) {
+		return defaultThemeFS.ReadFile(themeDir + "/" + name + ".html")
+	})
+	if err != nil {
+		panic(fmt.Sprintf("embedded theme is invalid: %v", err))
+	}
+	return theme
+}()
+
+// loadTheme parses the layouts from the content repo theme directory falling
+// back to the embedded default for any layout it does not override
+func loadTheme(contentDir string) (*Theme, error) {
+	overrides := filepath.Join(contentDir, themeDir)
+	if _, err := os.Stat(overrides); err != nil {
+		return defaultTheme, nil
+	}
+
+	return parseTheme(func(name string) ([]byte, error) {
+		src, err := os.ReadFile(filepath.Join(overrides, name+".html")) // #nosec G304 -- theme is from our own git repo
+		if errors.Is(err, fs.ErrNotExist) {
+			return defaultThemeFS.ReadFile(themeDir + "/" + name + ".html")
+		}
+		return src, err
+	})
+}
+
+func parseTheme(read func(name string) ([]byte, error)) (*Theme, error) {
+	src, err := read("base")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base layout: %w", err)
+	}
+	base, err := template.New("base").Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base layout: %w", err)
+	}
+
+	theme := &Theme{
+		pages:     make(map[string]*template.Template, len(themePages)),
+		fragments: make(map[string]*template.Template, len(themeFragments)),
+	}
+	for _, name := range themePages {
+		src, err := read(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s layout: %w", name, err)
+		}
+		page, err := template.Must(base.Clone()).New(name).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s layout: %w", name, err)
+		}
+		theme.pages[name] = page
+	}
+	for _, name := range themeFragments {
+		src, err := read(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s layout: %w", name, err)
+		}
+		fragment, err := template.New(name).Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s layout: %w", name, err)
+		}
+		theme.fragments[name] = fragment
+	}
+	return theme, nil
+}
+
+func (t *Theme) page(name string, data PageData) ([]byte, error) {
+	data.Site = site
+	var buf bytes.Buffer
+	if err := t.pages[name].ExecuteTemplate(&buf, "base", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s layout: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (t *Theme) fragment(name string, data PageData) ([]byte, error) {
+	data.Site = site
+	var buf bytes.Buffer
+	if err := t.fragments[name].Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s layout: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// HomePage is the page served at / that the list and tag fragments load into
+func (t *Theme) HomePage() ([]byte, error) {
+	return t.page("home", PageData{})
+}
+
+// ArticlePage is the full page for an article. nav is empty outside a series
+func (t *Theme) ArticlePage(arti *Article, nav string) ([]byte, error) {
+	return t.page("article", PageData{Article: arti, Nav: template.HTML(nav)}) // #nosec G203 -- nav is built from escaped titles
+}
+
+func (t *Theme) SeriesPage(series *Series, parts []Article) ([]byte, error) {
+	return t.page("series", PageData{Series: series, Articles: parts})
+}
+
+// ErrorPage never fails so handlers can always answer. a broken error layout
+// falls back to the embedded one
+func (t *Theme) ErrorPage(status int, message string) []byte {
+	data := PageData{Status: status, Message: message}
+	page, err := t.page("error", data)
+	if err != nil && t != defaultTheme {
+		managerLogger.Error().Msgf("theme error layout failed, using default: %v", err)
+		page, err = defaultTheme.page("error", data)
+	}
+	if err != nil {
+		return []byte(fmt.Sprintf("<h1>%d</h1><p>%s</p>", status, html.EscapeString(message)))
+	}
+	return page
+}
+
+// ListFragment is the article list loaded into the home page
+func (t *Theme) ListFragment(articles []Article) ([]byte, error) {
+	return t.fragment("list", PageData{Articles: articles})
+}
+
+// TagFragment is the article list for a single tag
+func (t *Theme) TagFragment(tag string, articles []Article) ([]byte, error) {
+	return t.fragment("tag", PageData{Tag: tag, Articles: articles})
+}
@@ -0,0 +1,108 @@
+package blog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultTheme(t *testing.T) {
+	arti := &Article{
+		Title:   "Tom & Jerry",
+		URL:     "/article/tom-jerry",
+		Body:    []byte("<p>chase</p>"),
+		Summary: `a "quoted" summary`,
+		Date:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+
+		ReadingTime: 3,
+	}
+
+	page, err := defaultTheme.ArticlePage(arti, `<nav class="series-nav"></nav>`)
+	require.NoError(t, err)
+	require.Contains(t, string(page), "<title>Tom &amp; Jerry</title>")
+	require.Contains(t, string(page), `<meta name="description" content="a &#34;quoted&#34; summary">`)
+	require.Contains(t, string(page), `<p>chase</p><nav class="series-nav"></nav>`)
+	require.Contains(t, string(page), `class="home-link"`)
+
+	list, err := defaultTheme.ListFragment([]Article{*arti, *arti})
+	require.NoError(t, err)
+	item := `<li><a href="/article/tom-jerry">Tom &amp; Jerry</a> -- <span class="date">Mar 1, 2024</span> ` +
+		`<span class="reading-time">3 min read</span><p class="summary">a &#34;quoted&#34; summary</p></li>`
+	require.Equal(t, item+"<br/>"+item, string(list))
+
+	require.Contains(t, string(defaultTheme.ErrorPage(404, "page not found")), "page not found")
+
+	home, err := defaultTheme.HomePage()
+	require.NoError(t, err)
+	require.Contains(t, string(home), "<title>Jacob Henning&#39;s Blog</title>")
+	require.Contains(t, string(home), `href="/styles.css"`)
+	require.NotContains(t, string(home), `href="/article.css"`, "the home page replaces the article styles")
+	require.NotContains(t, string(home), `class="home-link"`)
+	require.Contains(t, string(home), `hx-get="/content"`)
+	require.Contains(t, string(home), `<script src="/tabs.js"></script>`)
+}
+
+func TestHomeHandler(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	cfg.WebDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, themeDir), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.WebDir, "styles.css"), []byte("body{}"), 0o644))
+
+	bm := NewBlogManager(cfg)
+	s := NewServer(bm, nil)
+	require.NotNil(t, s)
+	handler := s.SetupRoutes()
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `hx-get="/content"`, "the default theme before the first update")
+	require.Equal(t, "body{}", get("/styles.css").Body.String(), "web assets are still served")
+
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, themeDir, "home.html"),
+		[]byte(`{{define "content"}}<main>{{.Site.Author}}</main>{{end}}`), 0o644))
+	require.NoError(t, bm.updateContent())
+	rec = get("/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "<main>"+siteAuthor+"</main>")
+}
+
+func TestThemeOverrides(t *testing.T) {
+	contentDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(contentDir, themeDir), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(contentDir, themeDir, "article.html"),
+		[]byte(`{{define "content"}}<article>{{.Article.HTML}}</article><footer>{{.Site.Author}}</footer>{{end}}`), 0o644))
+
+	theme, err := loadTheme(contentDir)
+	require.NoError(t, err)
+
+	page, err := theme.ArticlePage(&Article{Title: "Post", Body: []byte("<p>hi</p>")}, "")
+	require.NoError(t, err)
+	require.Contains(t, string(page), "<article><p>hi</p></article><footer>"+siteAuthor+"</footer>")
+	require.Contains(t, string(page), `class="home-link"`, "base layout still comes from the defaults")
+	require.Contains(t, string(page), "<title>Jacob Henning&#39;s Blog</title>", "blocks the override leaves out use the base defaults")
+
+	// layouts that are not overridden are the defaults
+	list, err := theme.ListFragment([]Article{{Title: "Post", URL: "/article/post"}})
+	require.NoError(t, err)
+	require.Contains(t, string(list), `<a href="/article/post">Post</a>`)
+
+	require.NoError(t, os.WriteFile(filepath.Join(contentDir, themeDir, "list.html"), []byte(`{{range}}`), 0o644))
+	_, err = loadTheme(contentDir)
+	require.Error(t, err)
+
+	theme, err = loadTheme(t.TempDir())
+	require.NoError(t, err)
+	require.Same(t, defaultTheme, theme)
+}
@@ -0,0 +1,125 @@
+package blog
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tocMarker is replaced by the table of contents. it renders as a paragraph of its
+// own with either engine
+const tocMarker = "<p>[[toc]]</p>"
+
+// tocEntry is a heading in the order it appears in the article
+type tocEntry struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// headingSlugger hands out heading ids that are unique within one article. the
+// same headings in the same order always get the same ids
+type headingSlugger struct {
+	used map[string]bool
+}
+
+func newHeadingSlugger() *headingSlugger {
+	return &headingSlugger{used: make(map[string]bool)}
+}
+
+// slug lower cases text and joins runs of letters and digits with hyphens.
+// repeats get -1, -2... in document order
+func (s *headingSlugger) slug(text string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == '\'' || r == '’':
+			// what's -> whats
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+		default:
+			pendingHyphen = true
+		}
+	}
+	base := b.String()
+	if base == "" {
+		base = "section"
+	}
+
+	id := base
+	for i := 1; s.used[id]; i++ {
+		id = base + "-" + strconv.Itoa(i)
+	}
+	s.used[id] = true
+	return id
+}
+
+// headingAnchor is the hover link placed inside each heading
+func headingAnchor(id string) string {
+	return ` <a class="heading-anchor" href="#` + id + `" aria-label="link to this section">#</a>`
+}
+
+// insertTOC replaces the [[toc]] marker with the table of contents. without a
+// marker the table is put at the top of articles with more than threshold
+// headings. a threshold of 0 only uses the marker
+func insertTOC(body []byte, headings []tocEntry, threshold int) []byte {
+	if bytes.Contains(body, []byte(tocMarker)) {
+		return bytes.Replace(body, []byte(tocMarker), []byte(renderTOC(headings)), 1)
+	}
+	if threshold > 0 && len(headings) > threshold {
+		return append([]byte(renderTOC(headings)+"\n"), body...)
+	}
+	return body
+}
+
+// renderTOC nests the headings as lists relative to the shallowest heading level.
+// skipped levels get an empty list item so the html stays valid
+func renderTOC(headings []tocEntry) string {
+	if len(headings) == 0 {
+		return ""
+	}
+	base := headings[0].Level
+	for _, h := range headings {
+		base = min(base, h.Level)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<nav class="toc">`)
+	depth := 0
+	for _, h := range headings {
+		level := h.Level - base + 1
+		if level > depth {
+			for depth < level {
+				b.WriteString("<ul>")
+				depth++
+				if depth < level {
+					b.WriteString("<li>")
+				}
+			}
+		} else {
+			b.WriteString("</li>")
+			for depth > level {
+				b.WriteString("</ul></li>")
+				depth--
+			}
+		}
+		b.WriteString(`<li><a href="#` + h.ID + `">` + html.EscapeString(h.Text) + "</a>")
+	}
+	b.WriteString("</li>")
+	for depth > 0 {
+		b.WriteString("</ul>")
+		depth--
+		if depth > 0 {
+			b.WriteString("</li>")
+		}
+	}
+	b.WriteString("</nav>")
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadingSlugger(t *testing.T) {
+	s := newHeadingSlugger()
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Getting Started", "getting-started"},
+		{"What's new in Go 1.26?", "whats-new-in-go-1-26"},
+		{"  --Setup--  ", "setup"},
+		{"Getting Started", "getting-started-1"},
+		{"getting started 1", "getting-started-1-1"},
+		{"Getting Started", "getting-started-2"},
+		{"Überblick", "überblick"},
+		{"???", "section"},
+		{"", "section-1"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, s.slug(tt.text), tt.text)
+	}
+}
+
+func TestRenderTOC(t *testing.T) {
+	headings := []tocEntry{
+		{Level: 2, ID: "a", Text: "A & B"},
+		{Level: 3, ID: "a1", Text: "A1"},
+		{Level: 2, ID: "b", Text: "B"},
+		{Level: 4, ID: "b1", Text: "B1"},
+		{Level: 2, ID: "c", Text: "C"},
+	}
+	want := `<nav class="toc"><ul>` +
+		`<li><a href="#a">A &amp; B</a><ul><li><a href="#a1">A1</a></li></ul></li>` +
+		`<li><a href="#b">B</a><ul><li><ul><li><a href="#b1">B1</a></li></ul></li></ul></li>` +
+		`<li><a href="#c">C</a></li>` +
+		`</ul></nav>`
+	require.Equal(t, want, renderTOC(headings))
+	require.Empty(t, renderTOC(nil))
+}
+
+func TestHeadingAnchorsAndTOC(t *testing.T) {
+	md := "# Intro\n\nsome text\n\n[[toc]]\n\n## Setup `go`\n\n## Setup go\n\n### Details\n"
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := NewRenderer(cfg).Render([]byte(md))
+			require.NoError(t, err)
+			html := string(out)
+
+			require.Contains(t, html, `<h1 id="intro">Intro`+headingAnchor("intro")+`</h1>`)
+			require.Contains(t, html, `<h2 id="setup-go">`)
+			require.Contains(t, html, `<h2 id="setup-go-1">`)
+			require.Contains(t, html, `<h3 id="details">`)
+
+			require.NotContains(t, html, "[[toc]]")
+			toc := strings.Index(html, `<nav class="toc">`)
+			require.Greater(t, toc, strings.Index(html, "some text"), "toc replaces the marker")
+			require.Contains(t, html, `<li><a href="#setup-go">Setup go</a></li><li><a href="#setup-go-1">Setup go</a><ul><li><a href="#details">Details</a>`)
+
+			again, err := NewRenderer(cfg).Render([]byte(md))
+			require.NoError(t, err)
+			require.Equal(t, out, again, "ids are deterministic")
+		})
+	}
+}
+
+func TestTOCThreshold(t *testing.T) {
+	md := []byte("## One\n\n## Two\n\n## Three\n")
+
+	for _, engine := range []string{EngineBlackfriday, EngineGoldmark} {
+		t.Run(engine, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MarkdownEngine = engine
+
+			out, err := NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.NotContains(t, string(out), `class="toc"`, "no marker and no threshold")
+
+			cfg.TOCThreshold = 3
+			out, err = NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.NotContains(t, string(out), `class="toc"`, "not above the threshold")
+
+			cfg.TOCThreshold = 2
+			out, err = NewRenderer(cfg).Render(md)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(out), `<nav class="toc">`))
+		})
+	}
+}
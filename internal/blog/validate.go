@@ -0,0 +1,202 @@
+package blog
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ValidationWarn   = "warn"   // publish with issues listed in the report
+	ValidationReject = "reject" // keep serving the previous content when there are issues
+)
+
+// validation checks, used as ValidationIssue.Check
+const (
+	checkBrokenLink     = "broken-link"
+	checkMissingImage   = "missing-image"
+	checkDuplicateSlug  = "duplicate-slug"
+	checkDuplicateTitle = "duplicate-title"
+	checkEmptyBody      = "empty-body"
+	checkFrontMatter    = "front-matter"
+	checkRender         = "render"
+	checkTheme          = "theme"
+	checkImage          = "image"
+)
+
+type ValidationIssue struct {
+	Check   string `json:"check"`
+	Source  string `json:"source"` // repo relative file
+	Message string `json:"message"`
+	// from a file an earlier accepted update already served stale. only a warning
+	// so a file the push did not fix does not reject the rest of it
+	Carried bool `json:"carried,omitempty"`
+}
+
+// UpdateReport is the outcome of one content update
+type UpdateReport struct {
+	Commit   string            `json:"commit"`
+	Time     time.Time         `json:"time"`
+	Rendered int               `json:"rendered"`
+	Reused   int               `json:"reused"`
+	Drafts   int               `json:"drafts"`
+	Serving  int               `json:"serving"`
+	Issues   []ValidationIssue `json:"issues"`
+	Stale    []string          `json:"stale"` // files that failed to re-render and serve their previous version
+	Rejected bool              `json:"rejected"`
+}
+
+// CheckSite loads the config like WithConfig and runs a content update without
+// serving it. the report has the validation issues a server would log
+func CheckSite(envPrefix string, opts ...ConfigOption) (UpdateReport, error) {
+	cfg, err := NewConfig(append([]ConfigOption{withEnvironment(envPrefix)}, opts...)...)
+	if err != nil {
+		return UpdateReport{}, err
+	}
+	if err := cfg.initializePrivateKey(); err != nil {
+		return UpdateReport{}, fmt.Errorf("private key initialization failed: %w", err)
+	}
+	// images are still processed and checked but never published
+	cfg.IMAGECACHE = false
+
+	bm := NewBlogManager(cfg)
+	err = bm.updateContent()
+	return bm.LastUpdateReport(), err
+}
+
+func (bm *BlogManager) LastUpdateReport() UpdateReport {
+	bm.reportMutex.Lock()
+	defer bm.reportMutex.Unlock()
+	return bm.report
+}
+
+func (bm *BlogManager) setUpdateReport(report UpdateReport) {
+	bm.reportMutex.Lock()
+	bm.report = report
+	bm.reportMutex.Unlock()
+}
+
+// renderIssue classifies an article that failed to load
+func renderIssue(source string, err error) ValidationIssue {
+	check := checkRender
+	if errors.Is(err, errMalformedFrontMatter) {
+		check = checkFrontMatter
+	}
+	return ValidationIssue{Check: check, Source: source, Message: err.Error()}
+}
+
+var htmlRefRe = regexp.MustCompile(`(href|src)="([^"]*)"`)
+
+// articleLinkSlug returns the slug an href points at when it is an internal article link
+func articleLinkSlug(href string) (string, bool) {
+	href = strings.TrimPrefix(href, siteURL)
+	u, err := url.Parse(href)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return "", false
+	}
+	rest, found := strings.CutPrefix(u.Path, "/article/")
+	if !found || strings.HasPrefix(rest, "images/") {
+		return "", false
+	}
+	return strings.TrimSuffix(rest, "/"), true
+}
+
+// localImageName returns the file under ContentDir/images an img src refers to
+// or links to in the image bucket
+func localImageName(src, bucketURL string) (string, bool) {
+	if bucketURL != "" {
+		if name, found := strings.CutPrefix(src, bucketURL); found {
+			return name, true
+		}
+	}
+	u, err := url.Parse(src)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return "", false
+	}
+	p := strings.TrimPrefix(strings.TrimPrefix(u.Path, "./"), "/article/")
+	name, found := strings.CutPrefix(p, "images/")
+	return name, found
+}
+
+// validateContent checks the articles about to be published. sources maps each
+// slug to the file it came from
+func validateContent(cfg *Config, rendered map[string]Article, sources map[string]string) []ValidationIssue {
+	var issues []ValidationIssue
+
+	slugs := make([]string, 0, len(rendered))
+	for slug := range rendered {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	titles := make(map[string]string) // lower cased title -> first slug using it
+	images := make(map[string]bool)   // memoized existence checks
+	for _, slug := range slugs {
+		arti := rendered[slug]
+		source := sources[slug]
+
+		title := strings.ToLower(strings.TrimSpace(arti.Title))
+		if first, dup := titles[title]; dup {
+			issues = append(issues, ValidationIssue{
+				Check: checkDuplicateTitle, Source: source,
+				Message: fmt.Sprintf("title %q is also used by %s", arti.Title, sources[first]),
+			})
+		} else {
+			titles[title] = slug
+		}
+
+		if text := stripHTML(string(arti.Body)); text == "" || text == strings.TrimSpace(arti.Title) {
+			issues = append(issues, ValidationIssue{Check: checkEmptyBody, Source: source, Message: "article has no content"})
+		}
+
+		for _, m := range htmlRefRe.FindAllStringSubmatch(string(arti.Body), -1) {
+			ref := m[2]
+			switch m[1] {
+			case "href":
+				target, internal := articleLinkSlug(ref)
+				if !internal {
+					continue
+				}
+				if _, exists := rendered[target]; !exists {
+					issues = append(issues, ValidationIssue{
+						Check: checkBrokenLink, Source: source,
+						Message: fmt.Sprintf("link to %s: no article with slug %q", ref, target),
+					})
+				}
+			case "src":
+				name, local := localImageName(ref, bucketPrefix(cfg.ImageBucketURL))
+				if !local && cfg.previewBranch != "" {
+					name, local = localImageName(ref, imageBaseURL(cfg))
+				}
+				if !local {
+					continue
+				}
+				exists, checked := images[name]
+				if !checked {
+					clean := path.Clean("/" + name)[1:]
+					_, err := os.Stat(filepath.Join(cfg.ContentDir, "images", filepath.FromSlash(clean)))
+					if err != nil {
+						// processed variants are only in the image directory
+						_, err = os.Stat(filepath.Join(imageDir(cfg), filepath.FromSlash(clean)))
+					}
+					exists = err == nil && clean == name
+					images[name] = exists
+				}
+				if !exists {
+					issues = append(issues, ValidationIssue{
+						Check: checkMissingImage, Source: source,
+						Message: fmt.Sprintf("image %s is not in images/", name),
+					})
+				}
+			}
+		}
+	}
+	return issues
+}
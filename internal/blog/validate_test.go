@@ -0,0 +1,135 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateContent(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentDir = t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.ContentDir, "images"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "images", "ok.png"), []byte("png"), 0o644))
+
+	rendered := map[string]Article{
+		"good": {Title: "Good", Slug: "good", Body: []byte(
+			`<h1>Good</h1><p>see <a href="/article/other">other</a> and <a href="https://jake-henning.com/article/other#part">again</a>` +
+				` and <a href="https://example.com/article/missing">elsewhere</a></p><img src="images/ok.png">`)},
+		"other": {Title: "Other", Slug: "other", Body: []byte(
+			`<h1>Other</h1><p><a href="/article/missing">gone</a></p><img src="` + cfg.ImageBucketURL + `nope.png"><img src="images/../secret">`)},
+		"copy":  {Title: "good", Slug: "copy", Body: []byte("<p>same title</p>")},
+		"empty": {Title: "Empty", Slug: "empty", Body: []byte("<h1>Empty</h1>\n")},
+	}
+	sources := map[string]string{"good": "good.md", "other": "other.md", "copy": "copy.md", "empty": "empty.md"}
+
+	byCheck := map[string][]string{}
+	for _, issue := range validateContent(cfg, rendered, sources) {
+		byCheck[issue.Check] = append(byCheck[issue.Check], issue.Source)
+	}
+
+	require.Equal(t, map[string][]string{
+		checkBrokenLink:     {"other.md"},
+		checkMissingImage:   {"other.md", "other.md"},
+		checkDuplicateTitle: {"good.md"},
+		checkEmptyBody:      {"empty.md"},
+	}, byCheck)
+}
+
+func TestUpdateValidationPolicy(t *testing.T) {
+	for _, policy := range []string{ValidationWarn, ValidationReject} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LocalOnly = true
+			cfg.ValidationPolicy = policy
+			cfg.ContentDir = t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "good.md"), []byte("# Good\n\nbody"), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "bad.md"), []byte("---\ntitle: [unclosed\n---\n# Bad"), 0o644))
+
+			bm := NewBlogManager(cfg)
+			err := bm.updateContent()
+
+			report := bm.LastUpdateReport()
+			require.Len(t, report.Issues, 1)
+			require.Equal(t, checkFrontMatter, report.Issues[0].Check)
+			require.Equal(t, "bad.md", report.Issues[0].Source)
+
+			_, served := bm.GetArticle("good")
+			if policy == ValidationReject {
+				require.Error(t, err)
+				require.True(t, report.Rejected)
+				require.False(t, served, "rejected update must not be swapped in")
+				return
+			}
+			require.NoError(t, err)
+			require.False(t, report.Rejected)
+			require.True(t, served)
+			require.Equal(t, 1, report.Serving)
+		})
+	}
+}
+
+func TestStaleArticleCarriedForward(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	file := filepath.Join(cfg.ContentDir, "post.md")
+	require.NoError(t, os.WriteFile(file, []byte("# Post\n\nfirst version"), 0o644))
+
+	bm := NewBlogManager(cfg)
+	require.NoError(t, bm.updateContent())
+
+	// a broken edit keeps the last good render live
+	require.NoError(t, os.WriteFile(file, []byte("---\ntitle: [unclosed\n---\n# Post"), 0o644))
+	require.NoError(t, bm.updateContent())
+	arti, served := bm.GetArticle("post")
+	require.True(t, served)
+	require.Contains(t, string(arti.Body), "first version")
+	require.Equal(t, []string{"post.md"}, bm.LastUpdateReport().Stale)
+
+	require.NoError(t, os.WriteFile(file, []byte("# Post\n\nfixed version"), 0o644))
+	require.NoError(t, bm.updateContent())
+	arti, _ = bm.GetArticle("post")
+	require.Contains(t, string(arti.Body), "fixed version")
+	require.Empty(t, bm.LastUpdateReport().Stale)
+
+	// a new file that never rendered has nothing to fall back on
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "new.md"), []byte("---\nbad: [\n---\n"), 0o644))
+	require.NoError(t, bm.updateContent())
+	_, served = bm.GetArticle("new")
+	require.False(t, served)
+}
+
+func TestCarriedIssuesDoNotReject(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalOnly = true
+	cfg.ContentDir = t.TempDir()
+	post := filepath.Join(cfg.ContentDir, "post.md")
+	require.NoError(t, os.WriteFile(post, []byte("# Post\n\nfirst version"), 0o644))
+
+	bm := NewBlogManager(cfg)
+	require.NoError(t, bm.updateContent())
+	require.NoError(t, os.WriteFile(post, []byte("---\ntitle: [unclosed\n---\n# Post"), 0o644))
+	require.NoError(t, bm.updateContent(), "accepted with the previous render while warning")
+	require.Equal(t, []string{"post.md"}, bm.LastUpdateReport().Stale)
+
+	// the still broken file does not hold back an update that only touches others
+	cfg.ValidationPolicy = ValidationReject
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "other.md"), []byte("# Other\n\nnew post"), 0o644))
+	require.NoError(t, bm.updateContent())
+	_, served := bm.GetArticle("other")
+	require.True(t, served)
+	report := bm.LastUpdateReport()
+	require.False(t, report.Rejected)
+	require.Len(t, report.Issues, 1)
+	require.True(t, report.Issues[0].Carried)
+
+	// newly broken files still reject
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "other.md"), []byte("---\nbad: [\n---\n"), 0o644))
+	require.Error(t, bm.updateContent())
+	report = bm.LastUpdateReport()
+	require.True(t, report.Rejected)
+	require.Len(t, report.Issues, 2)
+}
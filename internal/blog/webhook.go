@@ -0,0 +1,179 @@
+package blog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	webhookMaxBody    = 1 << 20 // push payloads with many commits are still well under this
+	webhookRateBurst  = 5
+	webhookRateRefill = 12 * time.Second // one delivery every 12s sustained
+)
+
+// rateLimiter is a token bucket shared by every caller
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	refill time.Duration // time to earn one token
+	last   time.Time
+}
+
+func newRateLimiter(burst int, refill time.Duration) *rateLimiter {
+	return &rateLimiter{
+		tokens: float64(burst),
+		burst:  float64(burst),
+		refill: refill,
+		last:   time.Now(),
+	}
+}
+
+func (rl *rateLimiter) Allow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	rl.tokens = min(rl.burst, rl.tokens+float64(now.Sub(rl.last))/float64(rl.refill))
+	rl.last = now
+
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
+
+// verifyWebhookSignature checks a hex encoded HMAC-SHA256 of the body. github sends
+// X-Hub-Signature-256 with a sha256= prefix, gitea sends X-Gitea-Signature without one
+func verifyWebhookSignature(secret string, body []byte, header http.Header) bool {
+	signature := strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if signature == "" {
+		signature = header.Get("X-Gitea-Signature")
+	}
+	if signature == "" {
+		return false
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func webhookEvent(header http.Header) string {
+	if event := header.Get("X-GitHub-Event"); event != "" {
+		return event
+	}
+	return header.Get("X-Gitea-Event")
+}
+
+func (s *Server) webhookRejected(ctx context.Context, reason string) {
+	s.webhookReject.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	s.webhookReject.Add(ctx, 1)
+}
+
+// wrapWebhook is the POST counterpart of wrapHandler for deliveries from the git host
+func (s *Server) wrapWebhook(h http.Handler, name string) http.Handler {
+	validateHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			s.webhookRejected(r.Context(), "BAD_METHOD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, webhookMaxBody)
+		h.ServeHTTP(w, r)
+	})
+
+	return otelhttp.NewHandler(validateHandler, name,
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return "Webhook " + r.URL.Path
+		}),
+	)
+}
+
+// ContentWebhook triggers a content update for signed push deliveries to the content
+// branch or one of the preview branches
+func (s *Server) ContentWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "ContentWebhook.Process")
+	defer span.End()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			s.webhookRejected(ctx, "BODY_TOO_LARGE")
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		s.webhookRejected(ctx, "BAD_BODY")
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyWebhookSignature(s.bm.Config.WebhookSecret, body, r.Header) {
+		span.SetAttributes(attribute.String("error", "bad signature"))
+		s.webhookRejected(ctx, "BAD_SIGNATURE")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// only signed deliveries are limited so forged ones cannot crowd out real pushes
+	if !s.webhookLimiter.Allow() {
+		s.webhookRejected(ctx, "RATE_LIMITED")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	event := webhookEvent(r.Header)
+	span.SetAttributes(attribute.String("webhook.event", event))
+	if event != "push" {
+		// github pings on creation, anything else is not a content change
+		s.webhookRejected(ctx, "IGNORED_EVENT")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var push struct {
+		Ref string `json:"ref"`
+	}
+	if err := json.Unmarshal(body, &push); err != nil {
+		s.webhookRejected(ctx, "BAD_PAYLOAD")
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("webhook.ref", push.Ref))
+
+	target := s.bm
+	branch, isBranch := strings.CutPrefix(push.Ref, "refs/heads/")
+	if !isBranch || branch != s.bm.Config.ContentBranch {
+		target = s.previews[branch]
+	}
+	if !isBranch || target == nil {
+		s.webhookRejected(ctx, "WRONG_BRANCH")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.webhookAccept.Add(ctx, 1)
+	target.TriggerUpdate()
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,157 @@
+package blog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func signBody(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestContentWebhook(t *testing.T) {
+	const secret = "hook-secret"
+	const mainPush = `{"ref":"refs/heads/main"}`
+
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		headers   map[string]string
+		want      int
+		triggered bool
+	}{
+		{
+			name:      "github push",
+			method:    http.MethodPost,
+			body:      mainPush,
+			headers:   map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + signBody(secret, mainPush)},
+			want:      http.StatusAccepted,
+			triggered: true,
+		},
+		{
+			name:      "gitea push",
+			method:    http.MethodPost,
+			body:      mainPush,
+			headers:   map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": signBody(secret, mainPush)},
+			want:      http.StatusAccepted,
+			triggered: true,
+		},
+		{
+			name:    "wrong secret",
+			method:  http.MethodPost,
+			body:    mainPush,
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + signBody("other", mainPush)},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "unsigned",
+			method:  http.MethodPost,
+			body:    mainPush,
+			headers: map[string]string{"X-GitHub-Event": "push"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "other branch",
+			method:  http.MethodPost,
+			body:    `{"ref":"refs/heads/draft"}`,
+			headers: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + signBody(secret, `{"ref":"refs/heads/draft"}`)},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:    "ping",
+			method:  http.MethodPost,
+			body:    `{}`,
+			headers: map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + signBody(secret, `{}`)},
+			want:    http.StatusNoContent,
+		},
+		{
+			name:   "get",
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.WebhookSecret = secret
+			bm := NewBlogManager(cfg)
+			s := NewServer(bm, NewLocalTelemetryStorage())
+			require.NotNil(t, s)
+			handler := s.SetupRoutes()
+
+			req := httptest.NewRequest(tt.method, "/hooks/content", strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.want, rec.Code)
+			select {
+			case <-bm.updateChan:
+				require.True(t, tt.triggered, "update should not have been triggered")
+			default:
+				require.False(t, tt.triggered, "update should have been triggered")
+			}
+		})
+	}
+}
+
+func TestContentWebhookDisabled(t *testing.T) {
+	s := NewServer(NewBlogManager(DefaultConfig()), NewLocalTelemetryStorage())
+	rec := httptest.NewRecorder()
+	s.SetupRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hooks/content", strings.NewReader(`{}`)))
+	require.NotEqual(t, http.StatusAccepted, rec.Code)
+}
+
+func TestContentWebhookRateLimit(t *testing.T) {
+	const secret = "hook-secret"
+	const mainPush = `{"ref":"refs/heads/main"}`
+	cfg := DefaultConfig()
+	cfg.WebhookSecret = secret
+	s := NewServer(NewBlogManager(cfg), NewLocalTelemetryStorage())
+	require.NotNil(t, s)
+	handler := s.SetupRoutes()
+
+	deliver := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/content", strings.NewReader(mainPush))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range webhookRateBurst * 2 {
+		require.Equal(t, http.StatusUnauthorized, deliver(signBody("forged", mainPush)))
+	}
+	require.Equal(t, http.StatusAccepted, deliver(signBody(secret, mainPush)), "forged deliveries do not use up the limit")
+	for range webhookRateBurst - 1 {
+		deliver(signBody(secret, mainPush))
+	}
+	require.Equal(t, http.StatusTooManyRequests, deliver(signBody(secret, mainPush)))
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(2, time.Hour)
+	require.True(t, rl.Allow())
+	require.True(t, rl.Allow())
+	require.False(t, rl.Allow())
+
+	// a full refill period later one more delivery is allowed
+	rl.last = rl.last.Add(-time.Hour)
+	require.True(t, rl.Allow())
+	require.False(t, rl.Allow())
+}